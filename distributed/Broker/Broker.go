@@ -21,65 +21,70 @@ func checkerr(e error, lineno int){
 }
 
 var (
-	workers []*rpc.Client
+	workers []*worker
 	topicmx *sync.Mutex
+	// joined is closed and replaced whenever a worker subscribes
+	joined chan struct{}
+	// workerWait is how long a turn waits for a worker to subscribe when there are none left
+	workerWait time.Duration
+	// frameInterval is the least time between answers to Poll. Rounds of turns in between are merged into one frame
+	frameInterval time.Duration
 )
 
-// Calculate without modulo
-func sendCalls(p stubs.StubsParams, world [][]uint8) [][]uint8 {
-
-	var newWorld [][]uint8
-	var returnWorld []*rpc.Call
-	var responses []*stubs.IncrementResponse
+// metrics collects timings from Increment between calls to Broker.Metrics
+type metrics struct {
+	mutex     *sync.Mutex
+	since     time.Time
+	turns     int
+	stepTimes []time.Duration
+	steps     []int
+	sendTime  time.Duration
+}
 
-	topicmx.Lock()
-	workerHeight := p.ImageHeight / len(workers)
-	for i, work := range workers{
-		h := i*workerHeight + workerHeight
-		w := p.ImageWidth
-		var request stubs.IncrementRequest
-		if i == 0 && i == len(workers)-1 {
-			reducedWorld := world[len(world)-1:]
-			reducedWorld = append(reducedWorld,world...)
-			reducedWorld = append(reducedWorld, world[:1]...)
-			request = stubs.IncrementRequest{reducedWorld, i * workerHeight, h, w, p.ImageHeight, true, true}
-		} else if i == 0 {
-			reducedWorld := world[len(world)-1:]
-			reducedWorld = append(reducedWorld, world[i*workerHeight:h+1]...)
-			request = stubs.IncrementRequest{reducedWorld, i * workerHeight, h, w, p.ImageHeight, true, false}
-		} else if i == len(workers)-1 {
-			reducedWorld := world[i*workerHeight-1:h + p.ImageHeight%len(workers)]
-			reducedWorld = append(reducedWorld, world[:1]...)
-			request = stubs.IncrementRequest{reducedWorld, i * workerHeight, h + p.ImageHeight%len(workers), w, p.ImageHeight, false, true}
-		} else {
-			reducedWorld := world[i*workerHeight-1:h+1]
-			request = stubs.IncrementRequest{reducedWorld, i * workerHeight, h, w, p.ImageHeight, false, false}
+// turn records how long each worker spent computing one turn and how long the whole turn took
+func (m *metrics) turn(stepTimes []time.Duration, total time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for len(m.stepTimes) < len(stepTimes) {
+		m.stepTimes = append(m.stepTimes, 0)
+		m.steps = append(m.steps, 0)
+	}
+	var slowest time.Duration
+	for i, d := range stepTimes {
+		m.stepTimes[i] += d
+		m.steps[i]++
+		if d > slowest {
+			slowest = d
 		}
-		response := new(stubs.IncrementResponse)
-
-		worldCall := work.Go(stubs.NodeStep, request, response, nil)
-
-		returnWorld = append(returnWorld, worldCall)
-		responses = append(responses, response)
-
 	}
-	topicmx.Unlock()
+	// anything the slowest worker wasn't computing for was spent on the calls themselves
+	m.sendTime += total - slowest
+}
 
-	for i, r := range returnWorld{
-		call := <-r.Done
-		var response [][]uint8
-		if call.Error != nil{
-			topicmx.Lock()
-			fmt.Println("Client disconnected!")
-			workers = append(workers[:i], workers[i+1:]...)
-			topicmx.Unlock()
-			return nil
-		} else {
-			response = (*responses[i]).World
-			newWorld = append(newWorld, response...)
+// report gives the averages since the last report
+func (m *metrics) report(turns int, res *stubs.MetricsResponse) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	elapsed := time.Since(m.since)
+	done := turns - m.turns
+	res.Turns = turns
+	if elapsed > 0 {
+		res.TurnsPerSecond = float64(done) / elapsed.Seconds()
+	}
+	res.WorkerStepTimes = make([]time.Duration, len(m.stepTimes))
+	for i := range m.stepTimes {
+		if m.steps[i] > 0 {
+			res.WorkerStepTimes[i] = m.stepTimes[i] / time.Duration(m.steps[i])
 		}
 	}
-	return newWorld
+	if done > 0 {
+		res.SendTime = m.sendTime / time.Duration(done)
+	}
+	m.since = time.Now()
+	m.turns = turns
+	m.stepTimes = nil
+	m.steps = nil
+	m.sendTime = 0
 }
 
 // Gives an array of all alive cell locations
@@ -96,101 +101,159 @@ func getAlive(p stubs.StubsParams, world [][]uint8) []util.Cell {
 }
 
 type Broker struct {
-	b stubs.GameBoard
-	p stubs.StubsParams
-	mutex *sync.Mutex
-	isConnected bool
-	isAbleToQuit chan bool
 	listener net.Listener
-
+	// mutex guards the jobs, each job has its own mutex for its board
+	mutex *sync.Mutex
+	jobs map[int]*job
+	lastJob int
+	// depth is the -depth flag given to each job
+	depth int
+	scheduler *scheduler
 }
 
-func (s *Broker) Increment(req stubs.BoardRequest, res *stubs.BoardResponse) (err error){
-	if len(workers )<= 0{
-		return errors.New("No servers have subscribed to the broker")
-	}
-	s.isConnected = true
-	world := req.World
-	turns := 0
-	s.b = stubs.GameBoard{
-		World: world,
-		Turns: 0,
+func (s *Broker) Metrics(req stubs.MetricsRequest, res *stubs.MetricsResponse) (err error){
+	j, err := s.job(req.Job)
+	if err != nil {
+		return err
 	}
-	s.p =  req.Params
-
+	j.status.Lock()
+	turns := j.turn
+	j.status.Unlock()
+	j.metrics.report(turns, res)
+	return
+}
 
-	for ; turns < req.Params.Turns; turns ++{
-		if s.isConnected{
-			s.mutex.Lock()
-			s.b = stubs.GameBoard{
-				World: world,
-				Turns: turns,
-			}
-			s.mutex.Unlock()
-			callWorld := sendCalls(req.Params, world)
-			for ; callWorld == nil ; {
-				callWorld = sendCalls(req.Params, world)
-			}
-			world = callWorld
-		} else {
-			res.World = world
-			res.Turns = turns
-			res.Alive = getAlive(req.Params, world)
-			s.mutex.Lock()
-			s.isAbleToQuit <- true
-			s.mutex.Unlock()
-			return
+func (s *Broker) KeyP(req *stubs.KeyPRequest, res *stubs.KeyPResponse) (err error){
+	j, err := s.job(req.Job)
+	if err != nil {
+		return err
+	}
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	if !req.Paused {
+		// is not paused, and the mutex being free means the job is between turns
+		if j.finished {
+			return errJobFinished
 		}
+		j.isPaused = true
+	} else {
+		// is paused
+		err = j.checkPaused()
+		if err != nil {
+			return err
+		}
+		j.isPaused = false
+		j.throttle.resume()
+		j.resumed.Broadcast()
 	}
-	res.Alive = getAlive(req.Params, world)
-	res.World = world
-	res.Turns = turns
-
+	res.Turn = j.b.Turns
+	j.setStatus(j.b.Turns, j.isPaused)
+	return
+}
+// KeyN runs a single turn while paused
+func (s *Broker) KeyN(req stubs.KeyRequest, res *stubs.KeyNResponse) (err error){
+	j, err := s.job(req.Job)
+	if err != nil {
+		return err
+	}
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	err = j.checkPaused()
+	if err != nil {
+		return err
+	}
+	j.throttle.resume()
+	j.stepping = true
+	j.resumed.Broadcast()
+	for j.stepping && !j.finished {
+		j.resumed.Wait()
+	}
+	if j.stepping {
+		j.stepping = false
+		return errJobFinished
+	}
+	res.Turn = j.b.Turns
 	return
 }
 
-func (s *Broker) Report(_, res *stubs.TickerResponse) (err error){
-	s.mutex.Lock()
-	res.Alive = len(getAlive(s.p, s.b.World))
-	res.Turns = s.b.Turns
-	s.mutex.Unlock()
+// Edit sets a cell on the board while paused
+func (s *Broker) Edit(req stubs.EditRequest, res *stubs.EditResponse) (err error){
+	j, err := s.job(req.Job)
+	if err != nil {
+		return err
+	}
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	err = j.checkPaused()
+	if err != nil {
+		return err
+	}
+	world, err := j.world()
+	if err != nil {
+		return err
+	}
+	x, y := req.Cell.X, req.Cell.Y
+	if y < 0 || y >= len(world) || x < 0 || x >= len(world[y]) {
+		return errors.New("Edit is outside the board")
+	}
+	var value uint8
+	if req.Alive {
+		value = 255
+	}
+	res.Turn = j.b.Turns
+	if world[y][x] != value {
+		world[y][x] = value
+		res.Flipped = true
+		j.strips = nil
+	}
+	return
+}
 
+func (s *Broker) KeyFaster(req stubs.KeyRequest, res *stubs.SpeedResponse) (err error){
+	j, err := s.job(req.Job)
+	if err != nil {
+		return err
+	}
+	res.TurnsPerSecond = j.throttle.faster()
 	return
 }
 
-func (s *Broker) KeyP(req *stubs.KeyPRequest, res *stubs.KeyPResponse) (err error){
-	if !req.Paused {
-		// is not paused
-		s.mutex.Lock()
-		res.Turn = s.b.Turns
-	} else {
-		// is paused
-		res.Turn = s.b.Turns
-		s.mutex.Unlock()
+func (s *Broker) KeySlower(req stubs.KeyRequest, res *stubs.SpeedResponse) (err error){
+	j, err := s.job(req.Job)
+	if err != nil {
+		return err
 	}
+	res.TurnsPerSecond = j.throttle.slower()
 	return
 }
-func (s *Broker) KeyQ(_, res *stubs.KeyQResponse) (err error){
-	s.mutex.Lock()
-	s.isConnected = false
-	s.mutex.Unlock()
-	<-s.isAbleToQuit
+
+func (s *Broker) KeyQ(req stubs.KeyRequest, res *stubs.KeyQResponse) (err error){
+	j, err := s.job(req.Job)
+	if err != nil {
+		return err
+	}
+	j.quit()
 	return
 }
-func (s *Broker) KeyK(_, res *stubs.KeyKResponse) (err error){
-	s.mutex.Lock()
-	s.isConnected = false
-	s.mutex.Unlock()
 
-	<-s.isAbleToQuit
+// KeyK stops the caller's job and shuts down the workers and the broker. Any other jobs fail as the workers go
+func (s *Broker) KeyK(req stubs.KeyRequest, res *stubs.KeyKResponse) (err error){
+	j, err := s.job(req.Job)
+	if err != nil {
+		return err
+	}
+	j.quit()
 	// this doesn't exist (don't look)
 	time.Sleep(1*time.Second)
 
-	for _, w := range workers {
+	topicmx.Lock()
+	pool := append([]*worker(nil), workers...)
+	topicmx.Unlock()
+	for _, w := range pool {
 		request := new(stubs.StatusReport)
 		response := new(stubs.StatusReport)
 
-		w.Call(stubs.Shutdown, request, response)
+		w.client.Call(stubs.Shutdown, request, response)
 
 	}
 
@@ -199,10 +262,14 @@ func (s *Broker) KeyK(_, res *stubs.KeyKResponse) (err error){
 	s.mutex.Unlock()
 	return
 }
-func (s *Broker) KeyS(_, res *stubs.KeySResponse) (err error){
-	s.mutex.Lock()
-	res.World = s.b.World
-	s.mutex.Unlock()
+func (s *Broker) KeyS(req stubs.KeyRequest, res *stubs.KeySResponse) (err error){
+	j, err := s.job(req.Job)
+	if err != nil {
+		return err
+	}
+	j.mutex.Lock()
+	res.World, err = j.world()
+	j.mutex.Unlock()
 	return
 }
 
@@ -213,7 +280,7 @@ func (s *Broker) Subscribe (req *stubs.SubscriptionRequest, res *stubs.StatusRep
 
 	if err == nil {
 		topicmx.Lock()
-		workers = append(workers, client)
+		addWorker(req.FactoryAddress, client)
 		topicmx.Unlock()
 		res.Message = "Connected to " + req.FactoryAddress
 	} else {
@@ -228,18 +295,43 @@ func (s *Broker) Subscribe (req *stubs.SubscriptionRequest, res *stubs.StatusRep
 
 
 func main(){
-	pAddr := flag.String("port","8030","Port to listen on")
+	// the port defaults to the one in the address clients and workers will look for
+	port := "8030"
+	if _, discovered, err := net.SplitHostPort(stubs.BrokerAddress()); err == nil {
+		port = discovered
+	}
+	pAddr := flag.String("port", port, "Port to listen on, from $GOL_BROKER or gol.conf if they give one")
+	ip := flag.String("ip", "", "Interface to listen on, all of them if not given")
+	flag.DurationVar(&workerWait, "wait", 10*time.Second, "How long to wait for a worker to subscribe when there are none left")
+	timeout := flag.Duration("timeout", 5*time.Second, "How long a worker can go without a heartbeat before it is dropped")
+	fps := flag.Int("fps", 30, "Most frames of progress a second sent to each client")
+	depth := flag.Int("depth", 0, "Turns computed by the workers for each round trip, 0 to pick it from the network and compute times")
+	list := flag.String("list", "", "Print the workers and jobs on the broker at this address and exit")
 	flag.Parse()
+	if *list != "" {
+		listWorkers(*list)
+		fmt.Println()
+		listJobs(*list)
+		return
+	}
 	topicmx = &sync.Mutex{}
+	joined = make(chan struct{})
+	if *fps > 0 {
+		frameInterval = time.Second / time.Duration(*fps)
+	}
+	go evictSilent(*timeout)
 
 	b := Broker{
 		mutex: &sync.Mutex{},
-		isAbleToQuit: make(chan bool),
+		jobs: make(map[int]*job),
+		depth: *depth,
+		scheduler: newScheduler(),
 	}
 
 	rpc.Register(&b)
 
-	listener, _ := net.Listen("tcp", ":"+*pAddr)
+	listener, err := net.Listen("tcp", net.JoinHostPort(*ip, *pAddr))
+	checkerr(err, 306)
 	defer listener.Close()
 	b.listener = listener
 
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// maxDepth is the most turns the workers compute for each round trip.
+const maxDepth = 64
+
+// depth picks how many turns the workers compute for each round trip, which is also how many rows deep
+// the halos they swap are. Deeper halos save round trips but mean computing more rows,
+// as the halo rows have to be computed too, one less row each turn.
+type depth struct {
+	mutex *sync.Mutex
+	// fixed is the depth given with -depth, 0 to pick it from the timings
+	fixed int
+	// overhead is a running average of the time a round trip spends not computing
+	overhead time.Duration
+	// rowTime is a running average of the time to compute one row for one turn
+	rowTime time.Duration
+}
+
+func newDepth(fixed int) *depth {
+	return &depth{mutex: &sync.Mutex{}, fixed: fixed}
+}
+
+// measured records a round trip of the given depth, how long it took, and the slowest worker's
+// compute time and strip height.
+func (d *depth) measured(turns int, roundTrip, stepTime time.Duration, height int) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	// each turn computes the strip and the halo rows that are still needed for the turns after it
+	rows := turns*height + turns*(turns-1)
+	if rows <= 0 {
+		return
+	}
+	overhead := roundTrip - stepTime
+	rowTime := stepTime / time.Duration(rows)
+	if d.rowTime == 0 {
+		d.overhead, d.rowTime = overhead, rowTime
+		return
+	}
+	d.overhead = (3*d.overhead + overhead) / 4
+	d.rowTime = (3*d.rowTime + rowTime) / 4
+}
+
+// turns gives the depth to use next. The time per turn is about overhead/k + rowTime*(height+k),
+// which is smallest at k = sqrt(overhead/rowTime).
+func (d *depth) turns() int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.fixed > 0 {
+		return d.fixed
+	}
+	if d.rowTime <= 0 || d.overhead <= 0 {
+		return 1
+	}
+	k := int(math.Sqrt(float64(d.overhead) / float64(d.rowTime)))
+	if k < 1 {
+		return 1
+	}
+	if k > maxDepth {
+		return maxDepth
+	}
+	return k
+}
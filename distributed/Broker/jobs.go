@@ -0,0 +1,537 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/rpc"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/stubs"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// job is one client's board, computed by the workers shared between every job.
+type job struct {
+	id           int
+	b            stubs.GameBoard
+	p            stubs.StubsParams
+	mutex        *sync.Mutex
+	isConnected  bool
+	isAbleToQuit chan bool
+	metrics      *metrics
+	// isPaused holds the job between turns until resumed signals it, or stepping asks for a single turn.
+	// finished is set once the job has stopped, so that it is no longer waited on. All are guarded by mutex
+	isPaused bool
+	stepping bool
+	finished bool
+	resumed  *sync.Cond
+	throttle *throttle
+	// strips are held by the workers at stripTurn, while j.b.World is the board as it was last gathered at worldTurn.
+	// strips is nil when the board has to be loaded into the workers again
+	strips    []*strip
+	stripTurn int
+	worldTurn int
+	// assignments counts the times strips have been loaded
+	assignments int
+	depth       *depth
+	scheduler   *scheduler
+	// alive counts the alive cells in the strips at stripTurn, and flipped are the cells changed since the last progress
+	alive   int
+	flipped []util.Cell
+	// gathered is when the board was last gathered from the workers
+	gathered time.Time
+
+	// status is kept apart from mutex, which is held for a whole turn, so that ListJobs can always see it
+	status  *sync.Mutex
+	turn    int
+	paused  bool
+	started time.Time
+	// attached is set while a client is controlling the job. Closing detach lets its call to Result return early.
+	// polled is when the client last called Poll, so that a client that has gone without detaching can be told
+	attached bool
+	detach   chan struct{}
+	polled   time.Time
+	// progress holds the latest rounds of turns for Poll. Closing progressed wakes the calls waiting for more.
+	// Rounds after taken, the last turn given out by Poll at answered, are merged until Poll collects them
+	progress   []stubs.Progress
+	progressed chan struct{}
+	taken      int
+	answered   time.Time
+
+	// done is closed once the job has finished, with its result or error
+	done   chan struct{}
+	result stubs.BoardResponse
+	err    error
+}
+
+func newJob(id int, req stubs.BoardRequest, depth int, scheduler *scheduler) *job {
+	mutex := &sync.Mutex{}
+	return &job{
+		id:           id,
+		b:            stubs.GameBoard{World: req.World},
+		p:            req.Params,
+		mutex:        mutex,
+		isConnected:  true,
+		isAbleToQuit: make(chan bool),
+		metrics:      &metrics{mutex: &sync.Mutex{}, since: time.Now()},
+		resumed:      sync.NewCond(mutex),
+		throttle:     newThrottle(),
+		depth:        newDepth(depth),
+		scheduler:    scheduler,
+		status:       &sync.Mutex{},
+		started:      time.Now(),
+		gathered:     time.Now(),
+		attached:     true,
+		polled:       time.Now(),
+		detach:       make(chan struct{}),
+		progressed:   make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+}
+
+const (
+	// checkpointEvery is how often the board is gathered from the workers while a job runs. A strip lost
+	// with its worker is caught up from the last board gathered, so without this the work to recover
+	// from a failure would grow with the length of the run
+	checkpointEvery = 2 * time.Second
+	// progressKept is how many rounds of progress are kept for clients that fall behind
+	progressKept = 64
+	// pollWait is the longest Poll waits for progress before answering with none
+	pollWait = 5 * time.Second
+	// pollLapse is how long a client attached to a job can go without polling before it is taken to have gone
+	pollLapse = 3 * pollWait
+	// resultKept is how long a finished job waits for its result to be collected before it is forgotten
+	resultKept = 10 * time.Minute
+)
+
+// controlled tells whether a client is attached to the job and still polling. Callers must hold j.status.
+func (j *job) controlled() bool {
+	return j.attached && time.Since(j.polled) < pollLapse
+}
+
+// run computes the job's turns, keeping the result for Result.
+func (j *job) run() {
+	j.err = j.increment(&j.result)
+	j.mutex.Lock()
+	j.finished = true
+	j.resumed.Broadcast()
+	j.mutex.Unlock()
+	// let the workers forget their strips
+	topicmx.Lock()
+	pool := append([]*worker(nil), workers...)
+	topicmx.Unlock()
+	for _, w := range pool {
+		w.client.Go(stubs.NodeDrop, stubs.JobRequest{Job: j.id}, new(stubs.StatusReport), nil)
+	}
+	close(j.done)
+}
+
+func (j *job) increment(res *stubs.BoardResponse) (err error) {
+	world := j.b.World
+	turns := 0
+
+	for turns < j.p.Turns {
+		j.throttle.wait()
+		// the mutex is held for the whole turn, so pausing always happens between turns
+		j.mutex.Lock()
+		for j.isPaused && !j.stepping && j.isConnected {
+			j.resumed.Wait()
+		}
+		if !j.isConnected {
+			world, err = j.world()
+			res.World = world
+			res.Turns = turns
+			res.Alive = getAlive(j.p, world)
+			j.isAbleToQuit <- true
+			j.mutex.Unlock()
+			return
+		}
+		// several turns are computed at once, unless stepping or held to a speed where each turn should be seen
+		next := turns + 1
+		if !j.stepping && !j.throttle.limited() {
+			next = turns + j.depth.turns()
+			if next > j.p.Turns {
+				next = j.p.Turns
+			}
+		}
+		// cells edited while paused are loaded into the workers again first
+		err = j.catchUp(next)
+		if err == nil {
+			turns = next
+			j.b.Turns = turns
+			j.setStatus(turns, j.isPaused)
+			j.publish(stubs.Progress{Turn: turns, Alive: j.alive, Flipped: j.flipped})
+			j.flipped = nil
+		}
+		// the last turn is gathered while the mutex is still held
+		if err == nil && (turns == j.p.Turns || time.Since(j.gathered) > checkpointEvery) {
+			world, err = j.world()
+		}
+		if err != nil {
+			j.isConnected = false
+			j.mutex.Unlock()
+			return err
+		}
+		if j.stepping {
+			j.stepping = false
+			j.resumed.Broadcast()
+		}
+		j.mutex.Unlock()
+	}
+	res.Alive = getAlive(j.p, world)
+	res.World = world
+	res.Turns = turns
+
+	return
+}
+
+// errJobFinished and errNotPaused refuse the calls that need a paused job
+var (
+	errJobFinished = errors.New("the job has finished")
+	errNotPaused   = errors.New("the job isn't paused")
+)
+
+// checkPaused gives an error unless the job is paused between turns. Callers must hold j.mutex.
+func (j *job) checkPaused() error {
+	if j.finished || !j.isConnected {
+		return errJobFinished
+	}
+	if !j.isPaused {
+		return errNotPaused
+	}
+	return nil
+}
+
+// quit stops the job at the end of the current turn, waiting for it to stop.
+func (j *job) quit() {
+	j.mutex.Lock()
+	j.isConnected = false
+	j.resumed.Broadcast()
+	j.mutex.Unlock()
+	select {
+	case <-j.isAbleToQuit:
+	case <-j.done:
+	}
+}
+
+func (j *job) setStatus(turn int, paused bool) {
+	j.status.Lock()
+	defer j.status.Unlock()
+	j.turn = turn
+	j.paused = paused
+}
+
+// publish records a round of turns and wakes the calls to Poll waiting for it.
+func (j *job) publish(progress stubs.Progress) {
+	j.status.Lock()
+	defer j.status.Unlock()
+	if last := len(j.progress) - 1; last >= 0 && j.progress[last].Turn > j.taken {
+		// the client is behind, so the frame it hasn't collected yet is brought up to date instead
+		progress.Flipped = mergeFlipped(j.progress[last].Flipped, progress.Flipped)
+		j.progress = j.progress[:last]
+	}
+	j.progress = append(j.progress, progress)
+	if len(j.progress) > progressKept {
+		j.progress = j.progress[len(j.progress)-progressKept:]
+	}
+	close(j.progressed)
+	j.progressed = make(chan struct{})
+}
+
+// mergeFlipped puts together the cells flipped by two rounds of turns. A cell flipped by both is back as it was
+func mergeFlipped(a, b []util.Cell) []util.Cell {
+	if len(a) == 0 {
+		return b
+	}
+	if len(b) == 0 {
+		return a
+	}
+	flips := make(map[util.Cell]bool, len(a)+len(b))
+	for _, cell := range a {
+		flips[cell] = !flips[cell]
+	}
+	for _, cell := range b {
+		flips[cell] = !flips[cell]
+	}
+	merged := make([]util.Cell, 0, len(flips))
+	for _, cells := range [][]util.Cell{a, b} {
+		for _, cell := range cells {
+			if flips[cell] {
+				merged = append(merged, cell)
+				flips[cell] = false
+			}
+		}
+	}
+	return merged
+}
+
+// scheduler hands the workers to one job's turns at a time, first come first served,
+// so that every job running gets a fair share of them.
+type scheduler struct {
+	mutex *sync.Mutex
+	busy  bool
+	queue []chan struct{}
+}
+
+func newScheduler() *scheduler {
+	return &scheduler{mutex: &sync.Mutex{}}
+}
+
+// wait blocks until it is the caller's go.
+func (s *scheduler) wait() {
+	s.mutex.Lock()
+	if !s.busy {
+		s.busy = true
+		s.mutex.Unlock()
+		return
+	}
+	next := make(chan struct{})
+	s.queue = append(s.queue, next)
+	s.mutex.Unlock()
+	<-next
+}
+
+// done hands the workers on to the next job waiting.
+func (s *scheduler) done() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.queue) == 0 {
+		s.busy = false
+		return
+	}
+	close(s.queue[0])
+	s.queue = s.queue[1:]
+}
+
+// job finds a job by its ID
+func (s *Broker) job(id int) (*job, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("there is no job %d", id)
+	}
+	return j, nil
+}
+
+// Submit starts computing a board, giving back the job ID for the other calls
+func (s *Broker) Submit(req stubs.BoardRequest, res *stubs.SubmitResponse) (err error) {
+	topicmx.Lock()
+	subscribed := len(workers)
+	topicmx.Unlock()
+	if subscribed <= 0 {
+		return errors.New("No servers have subscribed to the broker")
+	}
+
+	s.mutex.Lock()
+	s.lastJob++
+	j := newJob(s.lastJob, req, s.depth, s.scheduler)
+	s.jobs[j.id] = j
+	s.mutex.Unlock()
+
+	go func() {
+		j.run()
+		// a result nobody collects, as its client has gone, mustn't keep the job forever
+		time.Sleep(resultKept)
+		s.mutex.Lock()
+		delete(s.jobs, j.id)
+		s.mutex.Unlock()
+	}()
+	res.Job = j.id
+	return
+}
+
+// Poll gives back the progress of a job since the turn the client last saw, merged into one frame.
+// net/rpc can't call the client, so the broker pushes progress by holding the call until there is some,
+// for up to pollWait. Answers are kept frameInterval apart, so a client that can't keep up gets fewer, bigger frames
+func (s *Broker) Poll(req stubs.PollRequest, res *stubs.PollResponse) (err error) {
+	j, err := s.job(req.Job)
+	if err != nil {
+		return err
+	}
+	j.status.Lock()
+	j.polled = time.Now()
+	wait := time.Until(j.answered.Add(frameInterval))
+	j.status.Unlock()
+	select {
+	case <-time.After(wait):
+	case <-j.done:
+	}
+
+	timeout := time.After(pollWait)
+	for {
+		j.status.Lock()
+		var frame *stubs.Progress
+		for _, progress := range j.progress {
+			if progress.Turn <= req.After {
+				continue
+			}
+			if frame == nil {
+				frame = &stubs.Progress{}
+			}
+			frame.Turn, frame.Alive = progress.Turn, progress.Alive
+			frame.Flipped = mergeFlipped(frame.Flipped, progress.Flipped)
+		}
+		if frame != nil {
+			res.Progress = []stubs.Progress{*frame}
+			j.taken = frame.Turn
+			j.answered = time.Now()
+		}
+		progressed := j.progressed
+		j.status.Unlock()
+
+		select {
+		case <-j.done:
+			res.Finished = true
+			return
+		default:
+		}
+		if frame != nil {
+			return
+		}
+		select {
+		case <-progressed:
+		case <-j.done:
+		case <-timeout:
+			return
+		}
+	}
+}
+
+// Result waits for a job to finish and gives back the final board. The job is forgotten afterwards.
+// If the client detaches first, the board so far is given back instead and the job carries on
+func (s *Broker) Result(req stubs.JobRequest, res *stubs.BoardResponse) (err error) {
+	j, err := s.job(req.Job)
+	if err != nil {
+		return err
+	}
+	j.status.Lock()
+	detach := j.detach
+	j.status.Unlock()
+
+	select {
+	case <-j.done:
+	case <-detach:
+	}
+	select {
+	case <-j.done:
+		s.mutex.Lock()
+		delete(s.jobs, j.id)
+		s.mutex.Unlock()
+		*res = j.result
+		return j.err
+	default:
+		j.mutex.Lock()
+		defer j.mutex.Unlock()
+		res.World, err = j.world()
+		res.Turns = j.b.Turns
+		res.Alive = getAlive(j.p, res.World)
+		res.Detached = true
+		return
+	}
+}
+
+// Detach lets the client controlling a job go, leaving the job running for another client to attach to
+func (s *Broker) Detach(req stubs.JobRequest, res *stubs.StatusReport) (err error) {
+	j, err := s.job(req.Job)
+	if err != nil {
+		return err
+	}
+	j.status.Lock()
+	defer j.status.Unlock()
+	if j.attached {
+		j.attached = false
+		close(j.detach)
+	}
+	res.Message = fmt.Sprintf("Detached from job %d", j.id)
+	return
+}
+
+// Attach takes control of a job no client is controlling, giving back its board so far.
+// A finished job can be attached to as well, for its result
+func (s *Broker) Attach(req stubs.JobRequest, res *stubs.AttachResponse) (err error) {
+	j, err := s.job(req.Job)
+	if err != nil {
+		return err
+	}
+	j.status.Lock()
+	if j.controlled() {
+		j.status.Unlock()
+		return fmt.Errorf("job %d already has a client attached", j.id)
+	}
+	if j.attached {
+		// the client went without detaching
+		close(j.detach)
+	}
+	j.attached = true
+	j.polled = time.Now()
+	j.detach = make(chan struct{})
+	j.status.Unlock()
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	res.World, err = j.world()
+	res.Turn = j.b.Turns
+	res.Params = j.p
+	res.Paused = j.isPaused
+	// the new client starts from this board, so earlier flipped cells are no use to it
+	j.flipped = nil
+	j.status.Lock()
+	j.progress = nil
+	j.taken = j.b.Turns
+	j.status.Unlock()
+	return
+}
+
+// ListJobs reports on each job
+func (s *Broker) ListJobs(_ stubs.ListJobsRequest, res *stubs.ListJobsResponse) (err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, j := range s.jobs {
+		status := stubs.JobStatus{
+			Job:         j.id,
+			ImageWidth:  j.p.ImageWidth,
+			ImageHeight: j.p.ImageHeight,
+			Turns:       j.p.Turns,
+			Started:     j.started,
+			State:       "running",
+		}
+		j.status.Lock()
+		status.Turn = j.turn
+		if j.paused {
+			status.State = "paused"
+		} else if !j.controlled() {
+			status.State = "detached"
+		}
+		j.status.Unlock()
+		select {
+		case <-j.done:
+			status.State = "finished"
+		default:
+		}
+		res.Jobs = append(res.Jobs, status)
+	}
+	sort.Slice(res.Jobs, func(a, b int) bool { return res.Jobs[a].Job < res.Jobs[b].Job })
+	return
+}
+
+// listJobs prints the jobs on the broker at an address
+func listJobs(address string) {
+	client, err := rpc.Dial("tcp", address)
+	checkerr(err, 273)
+	defer client.Close()
+	response := new(stubs.ListJobsResponse)
+	err = client.Call(stubs.ListJobs, stubs.ListJobsRequest{}, response)
+	checkerr(err, 277)
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(writer, "JOB\tBOARD\tTURN\tSTATE\tSTARTED")
+	for _, j := range response.Jobs {
+		fmt.Fprintf(writer, "%d\t%dx%d\t%d/%d\t%v\t%v ago\n", j.Job, j.ImageWidth, j.ImageHeight, j.Turn, j.Turns, j.State, time.Since(j.Started).Round(time.Second))
+	}
+	writer.Flush()
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/rpc"
+	"strings"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/stubs"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// errWorkerFailed means a worker was dropped part way through, so the strips have to be loaded again.
+var errWorkerFailed = errors.New("a worker failed")
+
+// strip is a run of rows held by one worker between turns, from start up to but not including end.
+type strip struct {
+	start, end int
+	worker     *worker
+}
+
+// split shares the rows of the board out between the workers.
+func split(p stubs.StubsParams, pool []*worker) []*strip {
+	if len(pool) > p.ImageHeight {
+		pool = pool[:p.ImageHeight]
+	}
+	workerHeight := p.ImageHeight / len(pool)
+	strips := make([]*strip, len(pool))
+	for i, w := range pool {
+		strips[i] = &strip{start: i * workerHeight, end: (i + 1) * workerHeight, worker: w}
+	}
+	// the last strip picks up the rows left over
+	strips[len(strips)-1].end = p.ImageHeight
+	return strips
+}
+
+// failed drops the workers whose calls failed, giving the strips whose calls failed.
+// A worker that lost touch with a neighbour is kept, as it is the neighbour that will have failed,
+// and so is one that has lost its strip, which just needs loading again
+func failed(calls []*rpc.Call, strips []*strip) []int {
+	var lost []int
+	for i, call := range calls {
+		<-call.Done
+		if call.Error == nil {
+			continue
+		}
+		lost = append(lost, i)
+		if strings.HasPrefix(call.Error.Error(), stubs.PeerFailed) || strings.HasPrefix(call.Error.Error(), stubs.StripLost) {
+			fmt.Println("Worker", strips[i].worker.address, "needs its strip loading again:", call.Error)
+		} else {
+			fmt.Println("Worker failed:", call.Error)
+			removeWorker(strips[i].worker, "failed")
+		}
+	}
+	return lost
+}
+
+// neighbours gives the addresses of the workers holding the strips either side of strip i.
+func neighbours(strips []*strip, i int) (string, string) {
+	return strips[(i-1+len(strips))%len(strips)].worker.address, strips[(i+1)%len(strips)].worker.address
+}
+
+// loadRequest gives the request loading strip i from j.b.World, the board as it was at turn j.worldTurn,
+// caught up to the given turn. The rows needed to catch up are taken from either side of the strip.
+func (j *job) loadRequest(strips []*strip, i int, turn int, diff bool, diffFrom int) stubs.LoadRequest {
+	st := strips[i]
+	catchUp := turn - j.worldTurn
+	// past the size of the board it is cheaper for the worker to compute the whole board
+	first, last := st.start-catchUp, st.end+catchUp
+	wrap := last-first > j.p.ImageHeight
+	if wrap {
+		first, last = st.start, st.start+j.p.ImageHeight
+	}
+	rows := make([][]uint8, 0, last-first)
+	for y := first; y < last; y++ {
+		rows = append(rows, j.b.World[(y%j.p.ImageHeight+j.p.ImageHeight)%j.p.ImageHeight])
+	}
+	above, below := neighbours(strips, i)
+	return stubs.LoadRequest{
+		World:        rows,
+		StartHeight:  st.start,
+		EndHeight:    st.end,
+		Width:        j.p.ImageWidth,
+		ActualHeight: j.p.ImageHeight,
+		Above:        above,
+		Below:        below,
+		Assignment:   j.assignments,
+		Turn:         j.worldTurn,
+		Job:          j.id,
+		CatchUp:      catchUp,
+		Wrap:         wrap,
+		Diff:         diff,
+		DiffFrom:     diffFrom,
+	}
+}
+
+// load hands each worker its strip of j.b.World, the board as it was at turn j.worldTurn.
+func (j *job) load() error {
+	pool, err := waitForWorkers()
+	if err != nil {
+		return err
+	}
+	strips := split(j.p, pool)
+	j.assignments++
+	calls := make([]*rpc.Call, len(strips))
+	for i, st := range strips {
+		calls[i] = st.worker.client.Go(stubs.NodeLoad, j.loadRequest(strips, i, j.worldTurn, false, 0), new(stubs.LoadResponse), nil)
+	}
+	if len(failed(calls, strips)) > 0 {
+		return errWorkerFailed
+	}
+	j.strips = strips
+	j.stripTurn = j.worldTurn
+	return nil
+}
+
+// repair carries on after some strips were lost, because their workers failed or lost touch with a neighbour.
+// The strips still held at turn are kept as they are. Each lost strip is loaded again, merged into a neighbour
+// if its worker has gone, and caught up to turn from the last board gathered. It gives the responses
+// of the strips loaded again, or errWorkerFailed if no worker holding a strip is left.
+func (j *job) repair(lost []int, turn int, diff bool, diffFrom int) (map[*strip]*stubs.LoadResponse, error) {
+	reloaded := make(map[*strip]*stubs.LoadResponse)
+	for len(lost) > 0 {
+		reload := make(map[*strip]bool)
+		for _, i := range lost {
+			reload[j.strips[i]] = true
+		}
+		// the rows of a strip whose worker has gone go to the strip above, or below for the first strips
+		var strips []*strip
+		orphaned := -1
+		for _, st := range j.strips {
+			if !subscribed(st.worker) {
+				delete(reloaded, st)
+				if len(strips) > 0 {
+					strips[len(strips)-1].end = st.end
+					reload[strips[len(strips)-1]] = true
+				} else if orphaned < 0 {
+					orphaned = st.start
+				}
+				continue
+			}
+			if orphaned >= 0 {
+				st.start = orphaned
+				reload[st] = true
+				orphaned = -1
+			}
+			strips = append(strips, st)
+		}
+		if len(strips) == 0 {
+			return nil, errWorkerFailed
+		}
+		j.strips = strips
+		j.assignments++
+
+		calls := make([]*rpc.Call, len(strips))
+		for i, st := range strips {
+			if reload[st] {
+				reloaded[st] = new(stubs.LoadResponse)
+				calls[i] = st.worker.client.Go(stubs.NodeLoad, j.loadRequest(strips, i, turn, diff, diffFrom), reloaded[st], nil)
+			} else {
+				above, below := neighbours(strips, i)
+				request := stubs.RelinkRequest{Job: j.id, Turn: turn, Above: above, Below: below, Assignment: j.assignments}
+				calls[i] = st.worker.client.Go(stubs.NodeRelink, request, new(stubs.StatusReport), nil)
+			}
+		}
+		lost = failed(calls, strips)
+	}
+	j.stripTurn = turn
+	return reloaded, nil
+}
+
+// stepStrips has every worker compute up to turns turns of its strip. The workers swap edge rows between themselves,
+// so this is just the barrier at the end of the turns.
+func (j *job) stepStrips(turns int) error {
+	// the halos can't be deeper than the neighbouring strips
+	for _, st := range j.strips {
+		if st.end-st.start < turns {
+			turns = st.end - st.start
+		}
+	}
+	// turns computed again after a failure have been seen already, so only new turns are diffed
+	j.status.Lock()
+	diff := j.attached && j.stripTurn >= j.b.Turns
+	j.status.Unlock()
+	j.scheduler.wait()
+	start := time.Now()
+	calls := make([]*rpc.Call, len(j.strips))
+	responses := make([]*stubs.PeerStepResponse, len(j.strips))
+	for i, st := range j.strips {
+		responses[i] = new(stubs.PeerStepResponse)
+		request := stubs.PeerStepRequest{Job: j.id, Turn: j.stripTurn, Turns: turns, Diff: diff}
+		calls[i] = st.worker.client.Go(stubs.NodePeerStep, request, responses[i], nil)
+	}
+	lost := failed(calls, j.strips)
+	j.scheduler.done()
+	if len(lost) > 0 {
+		return j.stepLost(lost, turns, responses, diff)
+	}
+	roundTrip := time.Since(start)
+
+	stepTimes := make([]time.Duration, len(j.strips))
+	slowest := 0
+	j.alive = 0
+	// the strips don't overlap, so their flipped cells can simply be put together
+	var flipped []util.Cell
+	for i, st := range j.strips {
+		j.alive += responses[i].Alive
+		flipped = append(flipped, responses[i].Flipped...)
+		st.worker.stepped(turns, responses[i].StepTime)
+		stepTimes[i] = responses[i].StepTime / time.Duration(turns)
+		if responses[i].StepTime > responses[slowest].StepTime {
+			slowest = i
+		}
+	}
+	j.flipped = mergeFlipped(j.flipped, flipped)
+	j.depth.measured(turns, roundTrip, responses[slowest].StepTime, j.strips[slowest].end-j.strips[slowest].start)
+	j.stripTurn += turns
+	// metrics are kept per turn
+	for i := 0; i < turns; i++ {
+		j.metrics.turn(stepTimes, roundTrip/time.Duration(turns))
+	}
+	return nil
+}
+
+// stepLost finishes a round of turns in which some strips were lost. If any strips finished the round,
+// the lost ones are caught up to it, otherwise the round is left to be tried again.
+func (j *job) stepLost(lost []int, turns int, responses []*stubs.PeerStepResponse, diff bool) error {
+	stepped := make(map[*strip]*stubs.PeerStepResponse)
+	for i, st := range j.strips {
+		stepped[st] = responses[i]
+	}
+	for _, i := range lost {
+		delete(stepped, j.strips[i])
+	}
+	from := j.stripTurn
+	turn := from
+	if len(stepped) > 0 {
+		turn += turns
+	}
+	reloaded, err := j.repair(lost, turn, diff, from)
+	if err != nil {
+		return err
+	}
+
+	j.alive = 0
+	var flipped []util.Cell
+	for _, st := range j.strips {
+		if response, ok := reloaded[st]; ok {
+			j.alive += response.Alive
+			flipped = append(flipped, response.Flipped...)
+		} else {
+			j.alive += stepped[st].Alive
+			flipped = append(flipped, stepped[st].Flipped...)
+		}
+	}
+	j.flipped = mergeFlipped(j.flipped, flipped)
+	return nil
+}
+
+// gather collects the strips back into j.b.World.
+func (j *job) gather() error {
+	var responses []*stubs.GatherResponse
+	for {
+		calls := make([]*rpc.Call, len(j.strips))
+		responses = make([]*stubs.GatherResponse, len(j.strips))
+		for i, st := range j.strips {
+			responses[i] = new(stubs.GatherResponse)
+			calls[i] = st.worker.client.Go(stubs.NodeGather, stubs.JobRequest{Job: j.id}, responses[i], nil)
+		}
+		lost := failed(calls, j.strips)
+		if len(lost) == 0 {
+			break
+		}
+		_, err := j.repair(lost, j.stripTurn, false, 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	world := make([][]uint8, 0, j.p.ImageHeight)
+	for _, response := range responses {
+		world = append(world, response.World...)
+	}
+	j.b.World = world
+	j.worldTurn = j.stripTurn
+	j.gathered = time.Now()
+	return nil
+}
+
+// catchUp brings the workers' strips up to the given turn. When a worker fails only the strips lost are
+// computed again, from the last board gathered, which is gathered every checkpointEvery to keep that work small.
+// If every worker holding a strip fails, all the strips are loaded again and the turns since then computed again.
+func (j *job) catchUp(turn int) error {
+	for j.strips == nil || j.stripTurn < turn {
+		var err error
+		if j.strips == nil {
+			err = j.load()
+		} else {
+			turns := j.depth.turns()
+			if turn-j.stripTurn < turns {
+				turns = turn - j.stripTurn
+			}
+			// turns being computed again stop at the last turn the client saw, so that new turns are diffed on their own
+			if j.stripTurn < j.b.Turns && j.b.Turns-j.stripTurn < turns {
+				turns = j.b.Turns - j.stripTurn
+			}
+			err = j.stepStrips(turns)
+		}
+		if err == errWorkerFailed {
+			j.strips = nil
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// world gives the board at the current turn, gathering it from the workers if it has moved on since.
+// Callers must hold j.mutex.
+func (j *job) world() ([][]uint8, error) {
+	for j.worldTurn != j.b.Turns {
+		err := j.catchUp(j.b.Turns)
+		if err != nil {
+			return nil, err
+		}
+		err = j.gather()
+		if err == errWorkerFailed {
+			j.strips = nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return j.b.World, nil
+}
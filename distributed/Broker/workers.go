@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/rpc"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/stubs"
+)
+
+// worker is a subscribed GolServer.
+type worker struct {
+	address string
+	client  *rpc.Client
+	// lastSeen is the last heartbeat or step heard from the worker
+	lastSeen time.Time
+	steps    int
+	stepTime time.Duration
+}
+
+// addWorker subscribes the worker at an address, replacing any earlier subscription from the same address.
+// Callers must hold topicmx.
+func addWorker(address string, client *rpc.Client) {
+	for i, w := range workers {
+		if w.address == address {
+			w.client.Close()
+			workers = append(workers[:i], workers[i+1:]...)
+			break
+		}
+	}
+	workers = append(workers, &worker{address: address, client: client, lastSeen: time.Now()})
+	close(joined)
+	joined = make(chan struct{})
+}
+
+// removeWorker drops a worker that has failed, gone quiet or unsubscribed.
+// The same worker can fail for several strips, so it is found by value rather than by index
+func removeWorker(worker *worker, reason string) {
+	topicmx.Lock()
+	defer topicmx.Unlock()
+	for i, w := range workers {
+		if w == worker {
+			workers = append(workers[:i], workers[i+1:]...)
+			worker.client.Close()
+			fmt.Println("Worker", worker.address, reason+",", len(workers), "left")
+			return
+		}
+	}
+}
+
+// subscribed tells whether a worker is still subscribed.
+func subscribed(worker *worker) bool {
+	topicmx.Lock()
+	defer topicmx.Unlock()
+	for _, w := range workers {
+		if w == worker {
+			return true
+		}
+	}
+	return false
+}
+
+// findWorker gives the worker subscribed from an address. Callers must hold topicmx.
+func findWorker(address string) *worker {
+	for _, w := range workers {
+		if w.address == address {
+			return w
+		}
+	}
+	return nil
+}
+
+// stepped records turns computed by a worker.
+func (w *worker) stepped(turns int, stepTime time.Duration) {
+	topicmx.Lock()
+	defer topicmx.Unlock()
+	w.lastSeen = time.Now()
+	w.steps += turns
+	w.stepTime += stepTime
+}
+
+// waitForWorkers gives the workers subscribed, waiting up to workerWait for one to subscribe if there are none
+func waitForWorkers() ([]*worker, error) {
+	topicmx.Lock()
+	pool := append([]*worker(nil), workers...)
+	subscribed := joined
+	topicmx.Unlock()
+	if len(pool) > 0 {
+		return pool, nil
+	}
+
+	fmt.Println("No workers left, waiting", workerWait, "for one to subscribe")
+	select {
+	case <-subscribed:
+		return waitForWorkers()
+	case <-time.After(workerWait):
+		return nil, fmt.Errorf("no workers have been subscribed to the broker for %v", workerWait)
+	}
+}
+
+// evictSilent drops workers that haven't sent a heartbeat within timeout, checking every so often
+func evictSilent(timeout time.Duration) {
+	for range time.Tick(timeout / 4) {
+		topicmx.Lock()
+		var silent []*worker
+		for _, w := range workers {
+			if time.Since(w.lastSeen) > timeout {
+				silent = append(silent, w)
+			}
+		}
+		topicmx.Unlock()
+		for _, w := range silent {
+			removeWorker(w, "went quiet")
+		}
+	}
+}
+
+// Heartbeat tells the broker a worker is still there. Workers that have been evicted are told to subscribe again
+func (s *Broker) Heartbeat(req stubs.SubscriptionRequest, res *stubs.StatusReport) (err error) {
+	topicmx.Lock()
+	defer topicmx.Unlock()
+	w := findWorker(req.FactoryAddress)
+	if w == nil {
+		return errors.New(stubs.NotSubscribed)
+	}
+	w.lastSeen = time.Now()
+	res.Message = "ok"
+	return
+}
+
+// Unsubscribe lets a worker leave. Its connection is closed straight away, so any strip it held is computed again by the workers left
+func (s *Broker) Unsubscribe(req stubs.SubscriptionRequest, res *stubs.StatusReport) (err error) {
+	topicmx.Lock()
+	w := findWorker(req.FactoryAddress)
+	topicmx.Unlock()
+	if w == nil {
+		return errors.New(stubs.NotSubscribed)
+	}
+	removeWorker(w, "unsubscribed")
+	res.Message = "Disconnected from " + req.FactoryAddress
+	return
+}
+
+// ListWorkers reports on each subscribed worker
+func (s *Broker) ListWorkers(_ stubs.ListWorkersRequest, res *stubs.ListWorkersResponse) (err error) {
+	topicmx.Lock()
+	defer topicmx.Unlock()
+	for _, w := range workers {
+		status := stubs.WorkerStatus{Address: w.address, LastSeen: w.lastSeen, Steps: w.steps}
+		if w.steps > 0 {
+			status.StepTime = w.stepTime / time.Duration(w.steps)
+		}
+		res.Workers = append(res.Workers, status)
+	}
+	return
+}
+
+// listWorkers prints the workers subscribed to the broker at an address
+func listWorkers(address string) {
+	client, err := rpc.Dial("tcp", address)
+	checkerr(err, 151)
+	defer client.Close()
+	response := new(stubs.ListWorkersResponse)
+	err = client.Call(stubs.ListWorkers, stubs.ListWorkersRequest{}, response)
+	checkerr(err, 155)
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(writer, "ADDRESS\tLAST SEEN\tSTEPS\tAVERAGE STEP")
+	for _, w := range response.Workers {
+		fmt.Fprintf(writer, "%v\t%v ago\t%d\t%v\n", w.Address, time.Since(w.LastSeen).Round(time.Millisecond), w.Steps, w.StepTime)
+	}
+	writer.Flush()
+}
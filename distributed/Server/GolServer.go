@@ -1,12 +1,18 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"net"
 	"net/rpc"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 	"uk.ac.bris.cs/gameoflife/stubs"
+	"uk.ac.bris.cs/gameoflife/util"
 )
 
 func checkerr(e error, lineno int){
@@ -80,12 +86,117 @@ func calculateNextState(p stubs.IncrementRequest) [][]uint8 {
 
 type GameOfLifeBoard struct {
 	listener net.Listener
+	mutex    sync.Mutex
+	// strips are kept between turns for each job
+	strips map[int]*held
+	peers  map[string]*rpc.Client
 }
 
+// held is a strip kept by the worker between turns.
+type held struct {
+	// strip is the rows, as given by Load
+	strip stubs.LoadRequest
+	// turn is the turn the strip is at
+	turn  int
+	halos map[int]*halo
+}
+
+
+
+// stepRows computes turns turns of rows, losing a row from each end every turn.
+// rows must have at least turns rows above and below the strip wanted back
+func stepRows(rows [][]uint8, width int, turns int) [][]uint8 {
+	for i := 0; i < turns; i++ {
+		rows = calculateNextState(stubs.IncrementRequest{World: rows, StartHeight: 0, EndHeight: len(rows) - 2, Width: width})
+	}
+	return rows
+}
+
+// census counts the alive cells in a strip, and lists the cells that differ from before if it is given
+func census(rows [][]uint8, before [][]uint8, startHeight int) (int, []util.Cell) {
+	alive := 0
+	var flipped []util.Cell
+	for y, row := range rows {
+		for x, cell := range row {
+			if cell != 0 {
+				alive++
+			}
+			if before != nil && cell != before[y][x] {
+				flipped = append(flipped, util.Cell{X: x, Y: startHeight + y})
+			}
+		}
+	}
+	return alive, flipped
+}
+
+// Load keeps a strip of a job's board for PeerStep to work on, first computing any turns it has to catch up on
+func (s *GameOfLifeBoard) Load(req stubs.LoadRequest, res *stubs.LoadResponse) (err error){
+	rows := req.World
+	height := req.EndHeight - req.StartHeight
+	var before [][]uint8
+	for i := 0; i < req.CatchUp; i++ {
+		if req.Diff && req.Turn+i == req.DiffFrom {
+			if req.Wrap {
+				before = rows[:height]
+			} else {
+				before = rows[req.CatchUp-i : len(rows)-(req.CatchUp-i)]
+			}
+		}
+		if req.Wrap {
+			// the top and bottom rows are each other's neighbours
+			wrapped := append([][]uint8{rows[len(rows)-1]}, rows...)
+			rows = stepRows(append(wrapped, rows[0]), req.Width, 1)
+		} else {
+			rows = stepRows(rows, req.Width, 1)
+		}
+	}
+	if req.Wrap {
+		rows = rows[:height]
+	}
+	res.Alive, res.Flipped = census(rows, before, req.StartHeight)
+	req.World = rows
+	req.Turn += req.CatchUp
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.strips[req.Job] = &held{strip: req, turn: req.Turn, halos: make(map[int]*halo)}
+	return
+}
 
+// Relink gives a kept strip new neighbours, leaving its rows as they are
+func (s *GameOfLifeBoard) Relink(req stubs.RelinkRequest, res *stubs.StatusReport) (err error){
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	kept, ok := s.strips[req.Job]
+	if !ok || kept.turn != req.Turn {
+		return errors.New(stubs.StripLost + ": the strip kept is not at the turn asked for")
+	}
+	kept.strip.Above = req.Above
+	kept.strip.Below = req.Below
+	kept.strip.Assignment = req.Assignment
+	// edge rows sent for the old neighbours are no use now
+	kept.halos = make(map[int]*halo)
+	res.Message = "ok"
+	return
+}
 
-func (s *GameOfLifeBoard) NextStep(req stubs.IncrementRequest, res *stubs.IncrementResponse) (err error){
-	res.World = calculateNextState(req)
+// Gather gives back a job's strip
+func (s *GameOfLifeBoard) Gather(req stubs.JobRequest, res *stubs.GatherResponse) (err error){
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	h, ok := s.strips[req.Job]
+	if !ok {
+		return errors.New(stubs.StripLost + ": no strip has been loaded for the job")
+	}
+	res.World = h.strip.World
+	return
+}
+
+// Drop forgets a job's strip once the job has finished
+func (s *GameOfLifeBoard) Drop(req stubs.JobRequest, res *stubs.StatusReport) (err error){
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.strips, req.Job)
 	return
 }
 
@@ -95,13 +206,55 @@ func (s *GameOfLifeBoard) Shutdown(req stubs.StatusReport, res *stubs.StatusRepo
 }
 
 
+// broker is a worker's connection to the broker, which is dialled again whenever it is lost,
+// so that a worker rejoins a broker that has been restarted
+type broker struct {
+	mutex   *sync.Mutex
+	address string
+	client  *rpc.Client
+}
+
+// call makes a call to the broker, dialling it first if the connection was lost
+func (b *broker) call(method string, request stubs.SubscriptionRequest) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.client == nil {
+		client, err := rpc.Dial("tcp", b.address)
+		if err != nil {
+			return err
+		}
+		b.client = client
+	}
+	err := b.client.Call(method, request, new(stubs.StatusReport))
+	// an error the broker sent back leaves the connection working, anything else means it is gone
+	if _, answered := err.(rpc.ServerError); err != nil && !answered {
+		b.client.Close()
+		b.client = nil
+	}
+	return err
+}
+
+// advertised gives the address to reach the listener on. A listener on every interface is given
+// the address of the interface used to reach the broker, as that one should work for the others too
+func advertised(listener net.Listener, broker net.Conn) string {
+	host, port, err := net.SplitHostPort(listener.Addr().String())
+	checkerr(err, 164)
+	if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() {
+		host, _, err = net.SplitHostPort(broker.LocalAddr().String())
+		checkerr(err, 167)
+	}
+	return net.JoinHostPort(host, port)
+}
+
 func main() {
 	pAddr := flag.String("ip", "127.0.0.1:8050", "IP and port to listen on")
 	//sAddr := flag.String("address", "localhost:8050", "IP and port of the server")
-	brokerAddr := flag.String("broker","127.0.0.1:8030", "Address of broker instance")
+	advertise := flag.String("advertise", "", "Address the broker and other workers reach this worker on, worked out from -ip if not given")
+	brokerAddr := flag.String("broker", stubs.BrokerAddress(), "Address of broker instance, from $GOL_BROKER or gol.conf if they give one")
+	heartbeat := flag.Duration("heartbeat", time.Second, "How often to tell the broker this worker is still there")
 	flag.Parse()
 
-	g := GameOfLifeBoard{}
+	g := GameOfLifeBoard{strips: make(map[int]*held), peers: make(map[string]*rpc.Client)}
 
 	rpc.Register(&g)
 
@@ -110,17 +263,44 @@ func main() {
 	g.listener = listener
 	defer listener.Close()
 
-	client, err := rpc.Dial("tcp", *brokerAddr)
-	checkerr(err, 101)
+	conn, err := net.Dial("tcp", *brokerAddr)
+	checkerr(err, 190)
+	b := &broker{mutex: &sync.Mutex{}, address: *brokerAddr, client: rpc.NewClient(conn)}
+	if *advertise == "" {
+		*advertise = advertised(listener, conn)
+	}
+	fmt.Println("Listening on", listener.Addr(), "as", *advertise)
 
 	request := stubs.SubscriptionRequest{
-		FactoryAddress: *pAddr,
+		FactoryAddress: *advertise,
 	}
-	response := new(stubs.StatusReport)
-	client.Call(stubs.Subscribe, request, response)
+	b.call(stubs.Subscribe, request)
+
+	go func() {
+		for range time.Tick(*heartbeat) {
+			err := b.call(stubs.Heartbeat, request)
+			if err == nil {
+				continue
+			}
+			if err.Error() == stubs.NotSubscribed {
+				fmt.Println("Dropped by the broker, subscribing again")
+			} else {
+				fmt.Println("Lost the broker, subscribing again:", err)
+			}
+			b.call(stubs.Subscribe, request)
+		}
+	}()
 
+	// leave the broker cleanly when interrupted
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-interrupt
+		listener.Close()
+	}()
 
 	rpc.Accept(listener)
+	b.call(stubs.Unsubscribe, request)
 
 
 
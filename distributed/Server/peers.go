@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"net/rpc"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/stubs"
+)
+
+// haloTimeout is how long to wait for a neighbour's edge row before giving up on it.
+const haloTimeout = 10 * time.Second
+
+// halo holds the rows either side of the strip for one turn, as the neighbours send them.
+type halo struct {
+	above, below chan [][]uint8
+}
+
+// halo gives the halo for a turn, making it if no row for that turn has arrived yet. Callers must hold s.mutex.
+func (kept *held) halo(turn int) *halo {
+	h, ok := kept.halos[turn]
+	if !ok {
+		h = &halo{above: make(chan [][]uint8, 1), below: make(chan [][]uint8, 1)}
+		kept.halos[turn] = h
+	}
+	return h
+}
+
+// peer gives a connection to a neighbour, dialling it the first time.
+// The dial is made without holding s.mutex, so a slow neighbour doesn't hold up the other jobs' strips
+func (s *GameOfLifeBoard) peer(address string) (*rpc.Client, error) {
+	s.mutex.Lock()
+	client, ok := s.peers[address]
+	s.mutex.Unlock()
+	if ok {
+		return client, nil
+	}
+	client, err := rpc.Dial("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	// another call may have dialled the neighbour in the meantime
+	if kept, ok := s.peers[address]; ok {
+		client.Close()
+		return kept, nil
+	}
+	s.peers[address] = client
+	return client, nil
+}
+
+// sendHalo gives a neighbour the rows at one edge of the strip.
+func (s *GameOfLifeBoard) sendHalo(address string, row stubs.HaloRow) error {
+	client, err := s.peer(address)
+	if err == nil {
+		err = client.Call(stubs.NodeHalo, row, new(stubs.StatusReport))
+	}
+	if err != nil {
+		// dial again next time, in case the neighbour has come back
+		s.mutex.Lock()
+		if s.peers[address] == client {
+			delete(s.peers, address)
+		}
+		s.mutex.Unlock()
+		if client != nil {
+			client.Close()
+		}
+		return errors.New(stubs.PeerFailed + " " + address + ": " + err.Error())
+	}
+	return nil
+}
+
+// Halo takes edge rows from a neighbour. Rows from before the last Load of the job are ignored
+func (s *GameOfLifeBoard) Halo(req stubs.HaloRow, res *stubs.StatusReport) (err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	kept, ok := s.strips[req.Job]
+	if !ok || req.Assignment != kept.strip.Assignment || req.Turn < kept.turn {
+		return
+	}
+	h := kept.halo(req.Turn)
+	edge := h.below
+	if req.FromAbove {
+		edge = h.above
+	}
+	// never block holding the mutex, a row sent twice is just dropped
+	select {
+	case edge <- req.Rows:
+	default:
+	}
+	return
+}
+
+// PeerStep computes the next turns of the strip. The strip's edge rows are swapped with its neighbours directly,
+// as deep as the number of turns, so the broker only has to wait for every worker to finish
+func (s *GameOfLifeBoard) PeerStep(req stubs.PeerStepRequest, res *stubs.PeerStepResponse) (err error) {
+	turns := req.Turns
+	if turns < 1 {
+		turns = 1
+	}
+	s.mutex.Lock()
+	kept, ok := s.strips[req.Job]
+	if !ok || req.Turn != kept.turn {
+		s.mutex.Unlock()
+		return errors.New(stubs.StripLost + ": the strip loaded is not at the turn asked for")
+	}
+	strip := kept.strip
+	if turns > len(strip.World) {
+		s.mutex.Unlock()
+		return errors.New("the halo is deeper than the strip")
+	}
+	h := kept.halo(req.Turn)
+	s.mutex.Unlock()
+
+	// our top rows go below the strip above us, and our bottom rows above the strip below
+	sent := make(chan error, 2)
+	go func() {
+		sent <- s.sendHalo(strip.Above, stubs.HaloRow{Job: req.Job, Assignment: strip.Assignment, Turn: req.Turn, Rows: strip.World[:turns]})
+	}()
+	go func() {
+		sent <- s.sendHalo(strip.Below, stubs.HaloRow{Job: req.Job, Assignment: strip.Assignment, Turn: req.Turn, FromAbove: true, Rows: strip.World[len(strip.World)-turns:]})
+	}()
+	for i := 0; i < 2; i++ {
+		if err := <-sent; err != nil {
+			return err
+		}
+	}
+
+	var above, below [][]uint8
+	timeout := time.After(haloTimeout)
+	for above == nil || below == nil {
+		select {
+		case above = <-h.above:
+		case below = <-h.below:
+		case <-timeout:
+			return errors.New(stubs.PeerFailed + ": timed out waiting for an edge row")
+		}
+	}
+
+	start := time.Now()
+	rows := make([][]uint8, 0, len(strip.World)+2*turns)
+	rows = append(rows, above...)
+	rows = append(rows, strip.World...)
+	rows = append(rows, below...)
+	world := stepRows(rows, strip.Width, turns)
+	res.StepTime = time.Since(start)
+	var before [][]uint8
+	if req.Diff {
+		before = strip.World
+	}
+	res.Alive, res.Flipped = census(world, before, strip.StartHeight)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	// a Load or Drop while we were working replaces the strip, so this turn is thrown away
+	if s.strips[req.Job] == kept {
+		kept.strip.World = world
+		delete(kept.halos, kept.turn)
+		kept.turn += turns
+	}
+	return
+}
@@ -19,6 +19,8 @@ type distributorChannels struct {
 	ioOutput   chan<- uint8
 	ioInput    <-chan uint8
 	ioKeyPress <- chan rune
+	edits      <-chan Edit
+	metrics    *metrics
 }
 
 
@@ -30,116 +32,331 @@ func checkerr(e error, lineno int){
 	}
 }
 
-// Sends an alive cells count event every 2 seconds
-func reportAlive(d distributorChannels, done chan bool, conn *rpc.Client, mutex *sync.Mutex) {
+// Polls the broker for the job's progress, sending the flipped cells and a turn complete event for each frame
+// and an alive cells count event every 2 seconds. finished is closed once the last frame has been sent,
+// and a send on done stops it early
+func followJob(d distributorChannels, p Params, done chan bool, finished chan bool, conn *rpc.Client, mutex *sync.Mutex, job int, turn int) {
+	updates := make(chan stubs.PollResponse)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		after := turn
+		for {
+			response := new(stubs.PollResponse)
+			err := conn.Call(stubs.Poll, stubs.PollRequest{Job: job, After: after}, response)
+			select {
+			case <-stop:
+				// the job is forgotten once its result is taken
+				return
+			default:
+				checkerr(err, 49)
+			}
+			if len(response.Progress) > 0 {
+				after = response.Progress[len(response.Progress)-1].Turn
+			}
+			select {
+			case updates <- *response:
+			case <-stop:
+				return
+			}
+			if response.Finished {
+				return
+			}
+		}
+	}()
+
 	ticker := time.NewTicker(2 * time.Second)
-	flag := false
-	for !flag {
+	defer ticker.Stop()
+	alive := 0
+	for {
 		select {
+		case update := <-updates:
+			mutex.Lock()
+			for _, progress := range update.Progress {
+				if p.CellFlippedEvents {
+					for _, cell := range progress.Flipped {
+						d.events <- CellFlipped{CompletedTurns: progress.Turn, Cell: cell}
+					}
+				} else if len(progress.Flipped) > 0 {
+					d.events <- CellsFlipped{CompletedTurns: progress.Turn, Cells: progress.Flipped}
+				}
+				d.events <- TurnComplete{CompletedTurns: progress.Turn}
+				turn, alive = progress.Turn, progress.Alive
+			}
+			mutex.Unlock()
+			if update.Finished {
+				close(finished)
+				return
+			}
 		case <-ticker.C:
-			request := stubs.TickerRequest{}
-			response := new(stubs.TickerResponse)
-
-			err := conn.Call(stubs.Ticker, request, response)
+			metricsResponse := new(stubs.MetricsResponse)
+			err := conn.Call(stubs.Metrics, stubs.MetricsRequest{Job: job}, metricsResponse)
+			checkerr(err, 77)
 
-			checkerr(err, 45)
+			metrics := d.metrics.report(metricsResponse.Turns)
+			metrics.TurnsPerSecond = metricsResponse.TurnsPerSecond
+			metrics.WorkerStepTimes = metricsResponse.WorkerStepTimes
+			metrics.SendTime = metricsResponse.SendTime
 
 			mutex.Lock()
-			d.events <- AliveCellsCount{response.Turns, response.Alive}
+			d.events <- AliveCellsCount{turn, alive}
+			d.events <- metrics
 			mutex.Unlock()
-		case c := <-done:
-			flag = c
+		case <-done:
+			return
 		}
 	}
 }
 
-// Calls GolServer Increment inorder to get the world state
-func GetWorld(p Params, world [][]uint8, conn *rpc.Client) *stubs.BoardResponse {
+// Submits the world to the broker, giving back the job ID the other calls need
+func submitJob(p Params, world [][]uint8, conn *rpc.Client) int {
 	params := stubs.StubsParams{p.Turns,p.Threads,p.ImageWidth,p.ImageHeight}
 
 	request := stubs.BoardRequest{World: world, Params: params}
-	response := new(stubs.BoardResponse)
+	response := new(stubs.SubmitResponse)
 
-	err := conn.Call(stubs.GameOfLifeHandler, request, response)
+	err := conn.Call(stubs.Submit, request, response)
 
-	checkerr(err,65)
-	return response
+	checkerr(err,100)
+	return response.Job
 }
 
-//keypresses
-func keypress(c distributorChannels, p Params, fileName string, mutex *sync.Mutex, conn *rpc.Client) {
-	for {
-		switch <-c.ioKeyPress {
-		case 'p':
-			request := stubs.KeyPRequest{false}
-			response := new(stubs.KeyPResponse)
-			err := conn.Call(stubs.P, request, response)
-			checkerr(err,77)
+// Gives the address of the broker to use, from p.ServerDetails if it is set
+func brokerAddress(p Params) string {
+	if p.ServerDetails != "" {
+		return p.ServerDetails
+	}
+	return stubs.BrokerAddress()
+}
 
-			fmt.Println("Current turn ", response.Turn)
+// JobParams gives the board size and turns of the job p.Attach, so that the visualiser can be set up before attaching
+func JobParams(p Params) (Params, error) {
+	conn, err := rpc.Dial("tcp", brokerAddress(p))
+	if err != nil {
+		return p, err
+	}
+	defer conn.Close()
+	response := new(stubs.ListJobsResponse)
+	err = conn.Call(stubs.ListJobs, stubs.ListJobsRequest{}, response)
+	if err != nil {
+		return p, err
+	}
+	for _, job := range response.Jobs {
+		if job.Job == p.Attach {
+			p.ImageWidth = job.ImageWidth
+			p.ImageHeight = job.ImageHeight
+			p.Turns = job.Turns
+			return p, nil
+		}
+	}
+	return p, fmt.Errorf("there is no job %d on the broker", p.Attach)
+}
 
-			mutex.Lock()
-			c.events <- StateChange{CompletedTurns: response.Turn, NewState: Paused}
-			mutex.Unlock()
+// Takes control of a detached job, sending its board so far as flipped cells,
+// and gives back its turn and whether it was left paused
+func attachJob(p Params, c distributorChannels, conn *rpc.Client) (int, bool) {
+	response := new(stubs.AttachResponse)
+	err := conn.Call(stubs.Attach, stubs.JobRequest{Job: p.Attach}, response)
+	checkerr(err, 106)
+
+	var alive []util.Cell
+	for y, row := range response.World {
+		for x, cell := range row {
+			if cell == 0 {
+				continue
+			}
+			if p.CellFlippedEvents {
+				c.events <- CellFlipped{CompletedTurns: response.Turn, Cell: util.Cell{X: x, Y: y}}
+			} else {
+				alive = append(alive, util.Cell{X: x, Y: y})
+			}
+		}
+	}
+	if len(alive) > 0 {
+		c.events <- CellsFlipped{CompletedTurns: response.Turn, Cells: alive}
+	}
+	c.events <- TurnComplete{CompletedTurns: response.Turn}
+	if response.Paused {
+		fmt.Fprintln(Messages, "Job", p.Attach, "is paused at turn", response.Turn)
+		c.events <- StateChange{CompletedTurns: response.Turn, NewState: Paused}
+	}
+	return response.Turn, response.Paused
+}
+
+// Waits for the job to finish inorder to get the world state
+func GetWorld(job int, conn *rpc.Client) *stubs.BoardResponse {
+	response := new(stubs.BoardResponse)
+	err := conn.Call(stubs.Result, stubs.JobRequest{Job: job}, response)
+	checkerr(err,82)
+	return response
+}
 
-			for <-c.ioKeyPress != 'p'{}
+//keypresses. paused is set for a job attached to while it was left paused
+func keypress(c distributorChannels, p Params, fileName string, mutex *sync.Mutex, conn *rpc.Client, job int, paused bool, detached chan bool) {
+	for {
+		if paused {
+			var err error
+			for paused {
+				select {
+				case key := <-c.ioKeyPress:
+					switch KeyAction(key) {
+					case Pause:
+						paused = false
+					case Step:
+						stepResponse := new(stubs.KeyNResponse)
+						err = conn.Call(stubs.N, stubs.KeyRequest{Job: job}, stepResponse)
+						if err != nil {
+							paused = false
+							break
+						}
+						fmt.Fprintln(Messages, "Current turn ", stepResponse.Turn)
+					}
+				case edit := <-c.edits:
+					sendEdit(c, mutex, conn, job, edit)
+				}
+			}
+			// edits made before the key was pressed may still be queued, and belong to this turn
+			for queued := err == nil; queued; {
+				select {
+				case edit := <-c.edits:
+					sendEdit(c, mutex, conn, job, edit)
+				default:
+					queued = false
+				}
+			}
 
-			request = stubs.KeyPRequest{true}
+			if err != nil {
+				// the last turn was stepped through, so there is nothing left to resume
+				fmt.Fprintln(Messages, err)
+				continue
+			}
+			request := stubs.KeyPRequest{Job: job, Paused: true}
+			response := new(stubs.KeyPResponse)
 			err = conn.Call(stubs.P, request, response)
 			checkerr(err,89)
 
-			fmt.Println("Continuing")
+			fmt.Fprintln(Messages, "Continuing")
 			mutex.Lock()
 			c.events <- StateChange{CompletedTurns: response.Turn, NewState: Executing}
 			mutex.Unlock()
+			continue
+		}
+		switch action := KeyAction(<-c.ioKeyPress); action {
+		case Pause:
+			request := stubs.KeyPRequest{Job: job, Paused: false}
+			response := new(stubs.KeyPResponse)
+			err := conn.Call(stubs.P, request, response)
+			if err != nil {
+				// the job finished before it could be paused
+				fmt.Fprintln(Messages, err)
+				continue
+			}
+
+			fmt.Fprintln(Messages, "Current turn ", response.Turn)
 
-		case 'q':
 			mutex.Lock()
-			request := stubs.KeyRequest{}
-			response := new(stubs.KeyQResponse)
-			err := conn.Call(stubs.Q, request, response)
+			c.events <- StateChange{CompletedTurns: response.Turn, NewState: Paused}
+			mutex.Unlock()
+			paused = true
+		case Quit:
+			// the job carries on without us, GetWorld gives back the board so far
+			request := stubs.JobRequest{Job: job}
+			response := new(stubs.StatusReport)
+			err := conn.Call(stubs.Detach, request, response)
 			checkerr(err,101)
-		case 's':
-			request := stubs.KeyRequest{}
+			fmt.Fprintln(Messages, response.Message + ", use -attach", job, "to take control again")
+			select {
+			case detached <- true:
+			default:
+			}
+		case Snapshot:
+			request := stubs.KeyRequest{Job: job}
 			response := new(stubs.KeySResponse)
 			err := conn.Call(stubs.S, request, response)
 			checkerr(err,106)
 			outputFile(fileName, c, p, response.World)
-		case 'k':
-			request := stubs.KeyRequest{}
+		case Kill:
+			request := stubs.KeyRequest{Job: job}
 			response := new(stubs.KeyKResponse)
 			conn.Call(stubs.K, request, response)
+		case Faster, Slower:
+			call := stubs.Faster
+			if action == Slower {
+				call = stubs.Slower
+			}
+			response := new(stubs.SpeedResponse)
+			err := conn.Call(call, stubs.KeyRequest{Job: job}, response)
+			checkerr(err, 140)
+			if response.TurnsPerSecond == 0 {
+				fmt.Fprintln(Messages, "Speed: unlimited")
+			} else {
+				fmt.Fprintln(Messages, "Speed:", response.TurnsPerSecond, "turns/s")
+			}
 		}
 	}
 }
 
+// sendEdit has the broker apply an edit to the paused board, showing the cell if it flipped
+func sendEdit(c distributorChannels, mutex *sync.Mutex, conn *rpc.Client, job int, edit Edit) {
+	response := new(stubs.EditResponse)
+	err := conn.Call(stubs.Edit, stubs.EditRequest{Job: job, Cell: edit.Cell, Alive: edit.Alive}, response)
+	if err != nil {
+		fmt.Fprintln(Messages, err)
+	} else if response.Flipped {
+		mutex.Lock()
+		c.events <- CellFlipped{CompletedTurns: response.Turn, Cell: edit.Cell}
+		mutex.Unlock()
+	}
+}
+
 // distributor divides the work between workers and interacts with other goroutines.
 func distributor(p Params, c distributorChannels) {
 	// Sending name of file to io
 	filename := strconv.Itoa(p.ImageWidth) + "x" + strconv.Itoa(p.ImageHeight)
-	world := inputFile(filename, c, p)
 
 	turn := 0
 	done := make(chan bool)
 
-	conn, err := rpc.Dial("tcp", "localhost:8030")
+	conn, err := rpc.Dial("tcp", brokerAddress(p))
 	checkerr(err,126)
 	defer conn.Close()
 
 	var mutex = sync.Mutex{}
 
-	go reportAlive(c,done, conn, &mutex)
-	go keypress(c,p,filename,&mutex,conn)
-
-	response := GetWorld(p, world, conn)
+	job := p.Attach
+	paused := false
+	if job != 0 {
+		turn, paused = attachJob(p, c, conn)
+	} else {
+		world := inputFile(filename, c, p)
+		job = submitJob(p, world, conn)
+		fmt.Fprintln(Messages, "Started job", job)
+	}
+	finished := make(chan bool)
+	detached := make(chan bool, 1)
+	go followJob(c, p, done, finished, conn, &mutex, job, turn)
+	go keypress(c,p,filename,&mutex,conn, job, paused, detached)
+
+	// the result is only asked for once every frame has been seen, as the job is forgotten afterwards
+	select {
+	case <-finished:
+	case <-detached:
+		select {
+		case done <- true:
+		case <-finished:
+		}
+	}
+	response := GetWorld(job, conn)
 
 	turn = response.Turns
 	cells := response.Alive
-	world = response.World
-	done <- true
+	world := response.World
 	c.events <- FinalTurnComplete{turn, cells}
 
-	outputFile(filename, c, p, world)
+	// a detached job is still running, so there is no final board to write out
+	if !response.Detached {
+		outputFile(filename, c, p, world)
+	}
 
 	// Make sure that the Io has finished any output before exiting.
 	c.ioCommand <- ioCheckIdle
@@ -159,17 +376,25 @@ func inputFile(filename string, c distributorChannels, p Params) [][]uint8 {
 
 	// stores the starting state of the world
 	world := make([][]uint8, p.ImageHeight)
+	var alive []util.Cell
 	for y := 0; y < p.ImageHeight; y++ {
 		row := make([]uint8, p.ImageWidth)
 		for x := 0; x < p.ImageWidth; x++ {
 			row[x] = <-c.ioInput
 			// send a cell fliped event
 			if row[x] != 0 {
-				c.events <- CellFlipped{CompletedTurns: 0, Cell: util.Cell{X: x, Y: y}}
+				if p.CellFlippedEvents {
+					c.events <- CellFlipped{CompletedTurns: 0, Cell: util.Cell{X: x, Y: y}}
+				} else {
+					alive = append(alive, util.Cell{X: x, Y: y})
+				}
 			}
 		}
 		world[y] = row
 	}
+	if len(alive) > 0 {
+		c.events <- CellsFlipped{CompletedTurns: 0, Cells: alive}
+	}
 	return world
 }
 
@@ -0,0 +1,116 @@
+package gol
+
+import (
+	"net"
+	"net/rpc"
+	"sync"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/stubs"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// pausedBroker stands in for the broker, recording the turn each edit is applied to
+type pausedBroker struct {
+	mutex  *sync.Mutex
+	turn   int
+	edited map[util.Cell]int
+}
+
+func (b *pausedBroker) KeyP(req stubs.KeyPRequest, res *stubs.KeyPResponse) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	res.Turn = b.turn
+	return nil
+}
+
+func (b *pausedBroker) Edit(req stubs.EditRequest, res *stubs.EditResponse) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.edited[req.Cell] = b.turn
+	res.Turn = b.turn
+	res.Flipped = true
+	return nil
+}
+
+// pauseUntil pauses, sends the edits queued along with the key to resume,
+// and gives back the cells flipped before execution carried on.
+func pauseUntil(t *testing.T, keys chan rune, edits chan Edit, events chan Event, queued []Edit) []util.Cell {
+	t.Helper()
+	keys <- 'p'
+	if e, ok := next(t, events).(StateChange); !ok || e.NewState != Paused {
+		t.Fatalf("got %v on pausing, want Paused", e)
+	}
+	// the edits and the key to resume are both waiting, so either may be picked first
+	for _, edit := range queued {
+		edits <- edit
+	}
+	keys <- 'p'
+
+	var flipped []util.Cell
+	for {
+		switch e := next(t, events).(type) {
+		case CellFlipped:
+			flipped = append(flipped, e.Cell)
+		case StateChange:
+			if e.NewState != Executing {
+				t.Fatalf("got %v on resuming, want Executing", e)
+			}
+			return flipped
+		}
+	}
+}
+
+func next(t *testing.T, events chan Event) Event {
+	t.Helper()
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("no event from keypress")
+		return nil
+	}
+}
+
+// TestPauseEdits checks that the edits still queued when execution resumes are sent for the paused turn,
+// and not replayed on a later board at the next pause.
+func TestPauseEdits(t *testing.T) {
+	broker := &pausedBroker{mutex: &sync.Mutex{}, turn: 1, edited: make(map[util.Cell]int)}
+	server := rpc.NewServer()
+	err := server.RegisterName("Broker", broker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverConn, clientConn := net.Pipe()
+	go server.ServeConn(serverConn)
+	conn := rpc.NewClient(clientConn)
+	defer conn.Close()
+
+	keys := make(chan rune, 10)
+	edits := make(chan Edit, 100)
+	events := make(chan Event, 100)
+	c := distributorChannels{events: events, ioKeyPress: keys, edits: edits}
+	go keypress(c, Params{}, "", &sync.Mutex{}, conn, 1, false, make(chan bool, 1))
+
+	queued := make([]Edit, 20)
+	for i := range queued {
+		queued[i] = Edit{Cell: util.Cell{X: i, Y: 0}, Alive: true}
+	}
+	flipped := pauseUntil(t, keys, edits, events, queued)
+	if len(flipped) != len(queued) {
+		t.Errorf("%d of %d queued edits sent before resuming", len(flipped), len(queued))
+	}
+
+	broker.mutex.Lock()
+	broker.turn = 5
+	broker.mutex.Unlock()
+	if flipped := pauseUntil(t, keys, edits, events, nil); len(flipped) != 0 {
+		t.Errorf("%d edits replayed at the next pause", len(flipped))
+	}
+	for cell, turn := range broker.edited {
+		if turn != 1 {
+			t.Errorf("edit at %v applied at turn %d, want 1", cell, turn)
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package gol
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"time"
 	"uk.ac.bris.cs/gameoflife/util"
 )
 
@@ -28,6 +31,19 @@ type ImageOutputComplete struct { // implements Event
 	Filename       string
 }
 
+// Metrics is an Event reporting how fast the engine is running.
+// This Event is sent every 2s, covering the time since the last one.
+type Metrics struct { // implements Event
+	CompletedTurns int
+	TurnsPerSecond float64
+	// WorkerStepTimes holds how long each worker took to compute a turn, on average.
+	WorkerStepTimes []time.Duration
+	// SendTime is how long was spent sending on channels (or making RPC calls) per turn, on average.
+	SendTime time.Duration
+	// IoTime is the total time spent reading and writing images so far.
+	IoTime time.Duration
+}
+
 // State represents a change in the state of execution.
 type State int
 
@@ -52,9 +68,17 @@ type CellFlipped struct { // implements Event
 	Cell           util.Cell
 }
 
+// CellsFlipped is an Event notifying the GUI about a change of state of many cells at once.
+// Each worker sends one of these per turn instead of a CellFlipped for every changed cell,
+// unless Params.CellFlippedEvents is set.
+type CellsFlipped struct { // implements Event
+	CompletedTurns int
+	Cells          []util.Cell
+}
+
 // TurnComplete is an Event notifying the GUI about turn completion.
 // SDL will render a frame when this event is sent.
-// All CellFlipped events must be sent *before* TurnComplete.
+// All CellFlipped and CellsFlipped events must be sent *before* TurnComplete.
 type TurnComplete struct { // implements Event
 	CompletedTurns int
 }
@@ -98,6 +122,14 @@ func (event AliveCellsCount) GetCompletedTurns() int {
 	return event.CompletedTurns
 }
 
+func (event Metrics) String() string {
+	return fmt.Sprintf("%.1f turns/s", event.TurnsPerSecond)
+}
+
+func (event Metrics) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
 func (event ImageOutputComplete) String() string {
 	return fmt.Sprintf("File %v output complete", event.Filename)
 }
@@ -114,6 +146,14 @@ func (event CellFlipped) GetCompletedTurns() int {
 	return event.CompletedTurns
 }
 
+func (event CellsFlipped) String() string {
+	return fmt.Sprintf("")
+}
+
+func (event CellsFlipped) GetCompletedTurns() int {
+	return event.CompletedTurns
+}
+
 func (event TurnComplete) String() string {
 	return fmt.Sprintf("")
 }
@@ -130,6 +170,124 @@ func (event FinalTurnComplete) GetCompletedTurns() int {
 	return event.CompletedTurns
 }
 
+// JSON methods allow Events to be recorded to a file and read back in again.
+
+// MarshalText writes a State by name so recordings stay readable.
+func (state State) MarshalText() ([]byte, error) {
+	switch state {
+	case Paused, Executing, Quitting:
+		return []byte(state.String()), nil
+	default:
+		return nil, fmt.Errorf("cannot marshal state %d", int(state))
+	}
+}
+
+func (state *State) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "Paused":
+		*state = Paused
+	case "Executing":
+		*state = Executing
+	case "Quitting":
+		*state = Quitting
+	default:
+		return fmt.Errorf("unknown state %q", text)
+	}
+	return nil
+}
+
+// taggedEvent wraps an Event with the name of its type so it can be decoded into the right struct.
+type taggedEvent struct {
+	Type  string          `json:"type"`
+	Event json.RawMessage `json:"event"`
+}
+
+// eventType gives the name an Event is stored under.
+func eventType(event Event) (string, error) {
+	switch event.(type) {
+	case AliveCellsCount:
+		return "AliveCellsCount", nil
+	case ImageOutputComplete:
+		return "ImageOutputComplete", nil
+	case Metrics:
+		return "Metrics", nil
+	case StateChange:
+		return "StateChange", nil
+	case CellFlipped:
+		return "CellFlipped", nil
+	case CellsFlipped:
+		return "CellsFlipped", nil
+	case TurnComplete:
+		return "TurnComplete", nil
+	case FinalTurnComplete:
+		return "FinalTurnComplete", nil
+	default:
+		return "", fmt.Errorf("cannot marshal event of type %T", event)
+	}
+}
+
+// MarshalEvent encodes any Event as JSON, tagged with its type.
+func MarshalEvent(event Event) ([]byte, error) {
+	name, err := eventType(event)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(taggedEvent{Type: name, Event: data})
+}
+
+// UnmarshalEvent decodes an Event written by MarshalEvent.
+func UnmarshalEvent(data []byte) (Event, error) {
+	var tagged taggedEvent
+	err := json.Unmarshal(data, &tagged)
+	if err != nil {
+		return nil, err
+	}
+	if tagged.Event == nil {
+		return nil, errors.New("event is missing")
+	}
+
+	switch tagged.Type {
+	case "AliveCellsCount":
+		var event AliveCellsCount
+		err = json.Unmarshal(tagged.Event, &event)
+		return event, err
+	case "ImageOutputComplete":
+		var event ImageOutputComplete
+		err = json.Unmarshal(tagged.Event, &event)
+		return event, err
+	case "Metrics":
+		var event Metrics
+		err = json.Unmarshal(tagged.Event, &event)
+		return event, err
+	case "StateChange":
+		var event StateChange
+		err = json.Unmarshal(tagged.Event, &event)
+		return event, err
+	case "CellFlipped":
+		var event CellFlipped
+		err = json.Unmarshal(tagged.Event, &event)
+		return event, err
+	case "CellsFlipped":
+		var event CellsFlipped
+		err = json.Unmarshal(tagged.Event, &event)
+		return event, err
+	case "TurnComplete":
+		var event TurnComplete
+		err = json.Unmarshal(tagged.Event, &event)
+		return event, err
+	case "FinalTurnComplete":
+		var event FinalTurnComplete
+		err = json.Unmarshal(tagged.Event, &event)
+		return event, err
+	default:
+		return nil, fmt.Errorf("unknown event type %q", tagged.Type)
+	}
+}
+
 // This might all seem like weird syntax to you...
 // You have however seen something similar to it before in first year.
 
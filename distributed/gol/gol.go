@@ -1,16 +1,49 @@
 package gol
 
+import (
+	"io"
+	"os"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Messages is where messages for the user are written while a board runs.
+// The terminal visualiser draws over stdout, so main has them written to stderr instead.
+var Messages io.Writer = os.Stdout
+
 // Params provides the details of how to run the Game of Life and which image to load.
 type Params struct {
 	Turns       int
 	Threads     int
 	ImageWidth  int
 	ImageHeight int
+	// ServerDetails is the broker's address. Left empty, it is found with stubs.BrokerAddress.
 	ServerDetails string
+	// CellFlippedEvents sends a CellFlipped for every changed cell, as the tests expect,
+	// instead of batching them into CellsFlipped events.
+	CellFlippedEvents bool
+	// Attach is the ID of a detached job on the broker to take control of, instead of starting a new one.
+	// Use JobParams to fill in the rest of the Params from the job.
+	Attach int
+}
+
+// Rule is the rule being simulated, in birth/survival notation.
+const Rule = "B3/S23"
+
+// Edit sets a single cell on the board. Edits are only applied while execution is paused.
+type Edit struct {
+	Cell  util.Cell
+	Alive bool
 }
 
 // Run starts the processing of Game of Life. It should initialise channels and goroutines.
 func Run(p Params, events chan<- Event, keyPresses <-chan rune) {
+	RunEditable(p, events, keyPresses, nil)
+}
+
+// RunEditable is Run, but also sends any edits made to the board while execution is paused to the broker.
+// A CellFlipped event is sent for every cell an edit changes.
+func RunEditable(p Params, events chan<- Event, keyPresses <-chan rune, edits <-chan Edit) {
 
 	ioFilename 	:= make(chan string, 2)
 	ioOutput 	:= make(chan uint8, p.ImageWidth*p.ImageHeight)
@@ -26,7 +59,9 @@ func Run(p Params, events chan<- Event, keyPresses <-chan rune) {
 		output:   ioOutput,
 		input:    ioInput,
 	}
-	go startIo(p, ioChannels)
+	// the workers are timed by the broker, so only io is measured here
+	m := newMetrics(0)
+	go startIo(p, ioChannels, m)
 
 	distributorChannels := distributorChannels{
 		events:     events,
@@ -36,6 +71,8 @@ func Run(p Params, events chan<- Event, keyPresses <-chan rune) {
 		ioOutput:   ioOutput,
 		ioInput:    ioInput,
 		ioKeyPress: keyPresses,
+		edits:      edits,
+		metrics:    m,
 	}
 	distributor(p, distributorChannels)
 }
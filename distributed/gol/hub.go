@@ -0,0 +1,246 @@
+package gol
+
+import (
+	"sync"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// SlowPolicy decides what a Hub does with events for a subscriber whose buffer is full.
+type SlowPolicy int
+
+const (
+	// Block waits for the subscriber to make room, holding up the simulation until it does.
+	Block SlowPolicy = iota
+	// Drop throws away any event the subscriber has no room for.
+	Drop
+	// Coalesce merges the events the subscriber has no room for, so that when it catches up
+	// it jumps straight to the latest state. FinalTurnComplete and ImageOutputComplete are never merged away.
+	Coalesce
+)
+
+// coalesceAt is how many events a Coalesce subscriber can fall behind by before they are merged.
+const coalesceAt = 64
+
+type subscriber struct {
+	events chan Event
+	policy SlowPolicy
+
+	// only used by Coalesce subscribers
+	mutex   *sync.Mutex
+	pending []Event
+	wake    chan bool
+	gone    chan bool
+	closed  bool
+}
+
+// Hub takes the events from a single channel, such as the one given to Run,
+// and delivers every event to any number of independent subscribers.
+type Hub struct {
+	mutex       *sync.Mutex
+	subscribers []*subscriber
+}
+
+func NewHub() *Hub {
+	return &Hub{mutex: &sync.Mutex{}}
+}
+
+// Subscribe gives a new channel that receives every event from now on.
+// buffer is the size of the channel and policy decides what happens when it is full.
+// The channel is closed once the hub's input has been closed.
+func (h *Hub) Subscribe(buffer int, policy SlowPolicy) <-chan Event {
+	sub := &subscriber{
+		events: make(chan Event, buffer),
+		policy: policy,
+	}
+	if policy == Coalesce {
+		sub.mutex = &sync.Mutex{}
+		sub.wake = make(chan bool, 1)
+		sub.gone = make(chan bool)
+		go sub.pump()
+	}
+
+	h.mutex.Lock()
+	h.subscribers = append(h.subscribers, sub)
+	h.mutex.Unlock()
+	return sub.events
+}
+
+// Unsubscribe stops delivering events to a channel given by Subscribe and closes it.
+func (h *Hub) Unsubscribe(events <-chan Event) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for i, sub := range h.subscribers {
+		if sub.events == events {
+			h.subscribers = append(h.subscribers[:i], h.subscribers[i+1:]...)
+			if sub.policy == Coalesce {
+				// nobody is reading any more, so anything still pending is thrown away
+				close(sub.gone)
+			}
+			sub.close()
+			return
+		}
+	}
+}
+
+// Run delivers events to every subscriber until events is closed, then closes every subscriber.
+func (h *Hub) Run(events <-chan Event) {
+	for event := range events {
+		h.mutex.Lock()
+		for _, sub := range h.subscribers {
+			sub.deliver(event)
+		}
+		h.mutex.Unlock()
+	}
+
+	h.mutex.Lock()
+	for _, sub := range h.subscribers {
+		sub.close()
+	}
+	h.subscribers = nil
+	h.mutex.Unlock()
+}
+
+func (sub *subscriber) deliver(event Event) {
+	switch sub.policy {
+	case Block:
+		sub.events <- event
+	case Drop:
+		select {
+		case sub.events <- event:
+		default:
+		}
+	case Coalesce:
+		sub.mutex.Lock()
+		sub.pending = append(sub.pending, event)
+		if len(sub.pending) > coalesceAt {
+			sub.pending = coalesce(sub.pending)
+		}
+		sub.mutex.Unlock()
+		select {
+		case sub.wake <- true:
+		default:
+		}
+	}
+}
+
+func (sub *subscriber) close() {
+	if sub.policy != Coalesce {
+		close(sub.events)
+		return
+	}
+	sub.mutex.Lock()
+	sub.closed = true
+	sub.mutex.Unlock()
+	select {
+	case sub.wake <- true:
+	default:
+	}
+}
+
+// pump sends a Coalesce subscriber's pending events on to it, merging them whenever it has fallen behind.
+func (sub *subscriber) pump() {
+	for range sub.wake {
+		sub.mutex.Lock()
+		pending := sub.pending
+		closed := sub.closed
+		sub.pending = nil
+		sub.mutex.Unlock()
+
+		if len(pending) > 1 {
+			pending = coalesce(pending)
+		}
+		for _, event := range pending {
+			select {
+			case sub.events <- event:
+			case <-sub.gone:
+				close(sub.events)
+				return
+			}
+		}
+
+		sub.mutex.Lock()
+		done := closed && len(sub.pending) == 0
+		sub.mutex.Unlock()
+		if done {
+			close(sub.events)
+			return
+		}
+	}
+}
+
+// coalesce merges a run of events into the fewest events that leave a GUI in the same state.
+// Flipped cells are combined into CellsFlipped events and only the latest TurnComplete,
+// AliveCellsCount, Metrics and StateChange are kept between each FinalTurnComplete or ImageOutputComplete.
+func coalesce(events []Event) []Event {
+	var merged []Event
+	// done holds the cells flipped up to the latest TurnComplete, next the ones flipped since
+	done := make(map[util.Cell]bool)
+	next := make(map[util.Cell]bool)
+	doneTurn, nextTurn := 0, 0
+	var turn, alive, stats, state Event
+
+	// a cell flipped twice ends up where it started, so it can be left out
+	flip := func(cells map[util.Cell]bool, cell util.Cell) {
+		if cells[cell] {
+			delete(cells, cell)
+		} else {
+			cells[cell] = true
+		}
+	}
+
+	flipped := func(cells map[util.Cell]bool, completedTurns int) {
+		if len(cells) == 0 {
+			return
+		}
+		list := make([]util.Cell, 0, len(cells))
+		for cell := range cells {
+			list = append(list, cell)
+		}
+		merged = append(merged, CellsFlipped{CompletedTurns: completedTurns, Cells: list})
+	}
+
+	flush := func() {
+		flipped(done, doneTurn)
+		for _, event := range []Event{turn, alive, stats, state} {
+			if event != nil {
+				merged = append(merged, event)
+			}
+		}
+		flipped(next, nextTurn)
+		done = make(map[util.Cell]bool)
+		next = make(map[util.Cell]bool)
+		turn, alive, stats, state = nil, nil, nil, nil
+	}
+
+	for _, event := range events {
+		switch e := event.(type) {
+		case CellFlipped:
+			flip(next, e.Cell)
+			nextTurn = e.CompletedTurns
+		case CellsFlipped:
+			for _, cell := range e.Cells {
+				flip(next, cell)
+			}
+			nextTurn = e.CompletedTurns
+		case TurnComplete:
+			for cell := range next {
+				flip(done, cell)
+			}
+			next = make(map[util.Cell]bool)
+			doneTurn = nextTurn
+			turn = e
+		case AliveCellsCount:
+			alive = e
+		case Metrics:
+			stats = e
+		case StateChange:
+			state = e
+		default:
+			flush()
+			merged = append(merged, event)
+		}
+	}
+	flush()
+	return merged
+}
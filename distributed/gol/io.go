@@ -6,6 +6,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 	"uk.ac.bris.cs/gameoflife/util"
 )
 
@@ -22,6 +23,7 @@ type ioChannels struct {
 type ioState struct {
 	params   Params
 	channels ioChannels
+	metrics  *metrics
 }
 
 // ioCommand allows requesting behaviour from the io (pgm) goroutine.
@@ -83,7 +85,7 @@ func (io *ioState) writePgmImage() {
 	ioError = file.Sync()
 	util.Check(ioError)
 
-	fmt.Println("File", filename, "output done!")
+	fmt.Fprintln(Messages, "File", filename, "output done!")
 }
 
 // readPgmImage opens a pgm file and sends its data as an array of bytes.
@@ -122,25 +124,29 @@ func (io *ioState) readPgmImage() {
 		io.channels.input <- b
 	}
 
-	fmt.Println("File", filename, "input done!")
+	fmt.Fprintln(Messages, "File", filename, "input done!")
 }
 
 // startIo should be the entrypoint of the io goroutine.
-func startIo(p Params, c ioChannels) {
+func startIo(p Params, c ioChannels, m *metrics) {
 	io := ioState{
 		params:   p,
 		channels: c,
+		metrics:  m,
 	}
 
 	for {
 		select {
 		// Block and wait for requests from the distributor
 		case command := <-io.channels.command:
+			start := time.Now()
 			switch command {
 			case ioInput:
 				io.readPgmImage()
+				io.metrics.io(time.Since(start))
 			case ioOutput:
 				io.writePgmImage()
+				io.metrics.io(time.Since(start))
 			case ioCheckIdle:
 				io.channels.idle <- true
 			}
@@ -0,0 +1,165 @@
+package gol
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// metrics collects timings from the distributor, the workers and io between Metrics events.
+type metrics struct {
+	mutex     *sync.Mutex
+	since     time.Time
+	turns     int
+	stepTimes []time.Duration
+	steps     []int
+	sendTime  time.Duration
+	ioTime    time.Duration
+}
+
+func newMetrics(workers int) *metrics {
+	return &metrics{
+		mutex:     &sync.Mutex{},
+		since:     time.Now(),
+		stepTimes: make([]time.Duration, workers),
+		steps:     make([]int, workers),
+	}
+}
+
+// step records how long a worker took to compute its part of one turn.
+func (m *metrics) step(worker int, d time.Duration) {
+	m.mutex.Lock()
+	m.stepTimes[worker] += d
+	m.steps[worker]++
+	m.mutex.Unlock()
+}
+
+// send records time spent blocked sending on a channel.
+func (m *metrics) send(d time.Duration) {
+	m.mutex.Lock()
+	m.sendTime += d
+	m.mutex.Unlock()
+}
+
+// io records time spent reading or writing an image.
+func (m *metrics) io(d time.Duration) {
+	m.mutex.Lock()
+	m.ioTime += d
+	m.mutex.Unlock()
+}
+
+// report gives a Metrics event covering everything since the last report.
+func (m *metrics) report(completedTurns int) Metrics {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	elapsed := time.Since(m.since)
+	turns := completedTurns - m.turns
+	event := Metrics{
+		CompletedTurns:  completedTurns,
+		WorkerStepTimes: make([]time.Duration, len(m.stepTimes)),
+		IoTime:          m.ioTime,
+	}
+	if elapsed > 0 {
+		event.TurnsPerSecond = float64(turns) / elapsed.Seconds()
+	}
+	for i := range m.stepTimes {
+		if m.steps[i] > 0 {
+			event.WorkerStepTimes[i] = m.stepTimes[i] / time.Duration(m.steps[i])
+		}
+		m.stepTimes[i] = 0
+		m.steps[i] = 0
+	}
+	if turns > 0 {
+		event.SendTime = m.sendTime / time.Duration(turns)
+	}
+
+	m.since = time.Now()
+	m.turns = completedTurns
+	m.sendTime = 0
+	return event
+}
+
+// metricsServer serves the latest Metrics and AliveCellsCount events as Prometheus text on /metrics.
+type metricsServer struct {
+	mutex   *sync.Mutex
+	metrics Metrics
+	alive   AliveCellsCount
+	turns   int
+}
+
+// ServeMetrics starts an HTTP server on addr that reports on the events it is given.
+// A missing host is taken as localhost, so the endpoint is not exposed to the network by accident.
+func ServeMetrics(addr string, events <-chan Event) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if host == "" {
+		host = "localhost"
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return err
+	}
+
+	s := &metricsServer{mutex: &sync.Mutex{}}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", s)
+	go http.Serve(listener, mux)
+	go s.watch(events)
+	fmt.Fprintln(Messages, "Metrics on http://" + listener.Addr().String() + "/metrics")
+	return nil
+}
+
+func (s *metricsServer) watch(events <-chan Event) {
+	for event := range events {
+		s.mutex.Lock()
+		switch e := event.(type) {
+		case Metrics:
+			s.metrics = e
+		case AliveCellsCount:
+			s.alive = e
+		case TurnComplete:
+			s.turns = e.CompletedTurns
+		case FinalTurnComplete:
+			s.turns = e.CompletedTurns
+		}
+		s.mutex.Unlock()
+	}
+}
+
+func (s *metricsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	m := s.metrics
+	alive := s.alive
+	turns := s.turns
+	s.mutex.Unlock()
+	if m.CompletedTurns > turns {
+		turns = m.CompletedTurns
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintln(w, "# HELP gol_turns_completed Number of turns completed.")
+	fmt.Fprintln(w, "# TYPE gol_turns_completed counter")
+	fmt.Fprintln(w, "gol_turns_completed", turns)
+	fmt.Fprintln(w, "# HELP gol_turns_per_second Turns completed per second since the last report.")
+	fmt.Fprintln(w, "# TYPE gol_turns_per_second gauge")
+	fmt.Fprintln(w, "gol_turns_per_second", m.TurnsPerSecond)
+	fmt.Fprintln(w, "# HELP gol_alive_cells Number of alive cells at the last report.")
+	fmt.Fprintln(w, "# TYPE gol_alive_cells gauge")
+	fmt.Fprintln(w, "gol_alive_cells", alive.CellsCount)
+	fmt.Fprintln(w, "# HELP gol_worker_step_seconds Average time a worker took to compute one turn.")
+	fmt.Fprintln(w, "# TYPE gol_worker_step_seconds gauge")
+	for i, d := range m.WorkerStepTimes {
+		fmt.Fprintf(w, "gol_worker_step_seconds{worker=\"%d\"} %v\n", i, d.Seconds())
+	}
+	fmt.Fprintln(w, "# HELP gol_send_seconds Average time per turn spent sending on channels or making calls.")
+	fmt.Fprintln(w, "# TYPE gol_send_seconds gauge")
+	fmt.Fprintln(w, "gol_send_seconds", m.SendTime.Seconds())
+	fmt.Fprintln(w, "# HELP gol_io_seconds_total Total time spent reading and writing images.")
+	fmt.Fprintln(w, "# TYPE gol_io_seconds_total counter")
+	fmt.Fprintln(w, "gol_io_seconds_total", m.IoTime.Seconds())
+}
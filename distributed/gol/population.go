@@ -0,0 +1,125 @@
+package gol
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+)
+
+// maxPopulationPoints is how many points a PopulationHistory keeps before it starts thinning them out.
+const maxPopulationPoints = 1 << 20
+
+// PopulationPoint is the number of alive cells after a turn.
+type PopulationPoint struct {
+	Turn  int
+	Alive int
+}
+
+// PopulationHistory follows the population from turn to turn by counting cells as they flip.
+// Once it has too many points it keeps only every other one, and records half as often from then on,
+// so a long run is covered end to end in bounded memory.
+type PopulationHistory struct {
+	Points []PopulationPoint
+	width  int
+	cells  []bool
+	alive  int
+	// every is how many turns apart points are kept
+	every int
+}
+
+func NewPopulationHistory(p Params) *PopulationHistory {
+	return &PopulationHistory{
+		width: p.ImageWidth,
+		cells: make([]bool, p.ImageWidth*p.ImageHeight),
+		every: 1,
+	}
+}
+
+// Update takes in an event, reporting whether it added a point.
+func (h *PopulationHistory) Update(event Event) bool {
+	switch e := event.(type) {
+	case CellFlipped:
+		h.flip(e.Cell.X, e.Cell.Y)
+	case CellsFlipped:
+		for _, cell := range e.Cells {
+			h.flip(cell.X, cell.Y)
+		}
+	case TurnComplete:
+		return h.add(e.CompletedTurns)
+	case FinalTurnComplete:
+		// the final board is the one that counts, even if some flips never arrived
+		h.alive = len(e.Alive)
+		return h.add(e.CompletedTurns)
+	}
+	return false
+}
+
+func (h *PopulationHistory) flip(x, y int) {
+	i := y*h.width + x
+	h.cells[i] = !h.cells[i]
+	if h.cells[i] {
+		h.alive++
+	} else {
+		h.alive--
+	}
+}
+
+func (h *PopulationHistory) add(turn int) bool {
+	if turn%h.every != 0 {
+		return false
+	}
+	last := len(h.Points) - 1
+	if last >= 0 && h.Points[last].Turn == turn {
+		h.Points[last].Alive = h.alive
+		return true
+	}
+	if len(h.Points) == maxPopulationPoints {
+		h.every *= 2
+		kept := h.Points[:0]
+		for _, point := range h.Points {
+			if point.Turn%h.every == 0 {
+				kept = append(kept, point)
+			}
+		}
+		h.Points = kept
+		if turn%h.every != 0 {
+			return false
+		}
+	}
+	h.Points = append(h.Points, PopulationPoint{Turn: turn, Alive: h.alive})
+	return true
+}
+
+// Alive gives the current population.
+func (h *PopulationHistory) Alive() int {
+	return h.alive
+}
+
+// WriteCSV writes the history to a CSV file with a turn,alive header.
+func (h *PopulationHistory) WriteCSV(filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	fmt.Fprintln(writer, "turn,alive")
+	for _, point := range h.Points {
+		fmt.Fprintf(writer, "%d,%d\n", point.Turn, point.Alive)
+	}
+	err = writer.Flush()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// WritePopulation follows the population through every event until events is closed,
+// then writes its history to a CSV file.
+func WritePopulation(filename string, p Params, events <-chan Event) error {
+	h := NewPopulationHistory(p)
+	for event := range events {
+		h.Update(event)
+	}
+	return h.WriteCSV(filename)
+}
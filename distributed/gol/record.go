@@ -0,0 +1,239 @@
+package gol
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// recordLine is one line of a JSON Lines recording.
+// The first line of a recording holds the Params, every line after it holds one Event.
+type recordLine struct {
+	// Time is the number of milliseconds since the recording started.
+	Time   int64           `json:"t"`
+	Params *Params         `json:"params,omitempty"`
+	Event  json.RawMessage `json:"event,omitempty"`
+}
+
+// Recorder writes every Event it is given to a JSON Lines file.
+type Recorder struct {
+	file   *os.File
+	writer *bufio.Writer
+	start  time.Time
+}
+
+// NewRecorder creates the recording file and writes the Params needed to replay it.
+func NewRecorder(filename string, p Params) (*Recorder, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	r := &Recorder{
+		file:   file,
+		writer: bufio.NewWriter(file),
+		start:  time.Now(),
+	}
+	err = r.writeLine(recordLine{Params: &p})
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Recorder) writeLine(line recordLine) error {
+	data, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+	_, err = r.writer.Write(append(data, '\n'))
+	return err
+}
+
+// Record appends a single Event to the recording.
+func (r *Recorder) Record(event Event) error {
+	data, err := MarshalEvent(event)
+	if err != nil {
+		return err
+	}
+	return r.writeLine(recordLine{
+		Time:  time.Since(r.start).Nanoseconds() / int64(time.Millisecond),
+		Event: data,
+	})
+}
+
+// Close flushes anything still buffered and closes the file.
+func (r *Recorder) Close() error {
+	err := r.writer.Flush()
+	if err != nil {
+		r.file.Close()
+		return err
+	}
+	return r.file.Close()
+}
+
+// Record writes every event from events to the recording, finishing it once events is closed.
+func Record(r *Recorder, events <-chan Event) {
+	for event := range events {
+		err := r.Record(event)
+		if err != nil {
+			fmt.Fprintln(Messages, "Recording failed:", err)
+		}
+	}
+	err := r.Close()
+	if err != nil {
+		fmt.Fprintln(Messages, "Recording failed:", err)
+	}
+}
+
+// Player feeds a recording back out as a stream of events, with no engine running.
+type Player struct {
+	// Params are the Params the recording was made with.
+	Params Params
+	// Speed scales the gaps between events. 2 plays twice as fast, 0 plays as fast as possible.
+	Speed float64
+
+	file   *os.File
+	reader *bufio.Reader
+}
+
+// NewPlayer opens a recording and reads its Params.
+func NewPlayer(filename string) (*Player, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	pl := &Player{
+		Speed:  1,
+		file:   file,
+		reader: bufio.NewReader(file),
+	}
+	line, err := pl.readLine()
+	if err == nil && line.Params == nil {
+		err = errors.New("recording does not start with params")
+	}
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("reading %v: %v", filename, err)
+	}
+	pl.Params = *line.Params
+	return pl, nil
+}
+
+func (pl *Player) readLine() (recordLine, error) {
+	var line recordLine
+	data, err := pl.reader.ReadBytes('\n')
+	if err == io.EOF && len(data) > 0 {
+		err = nil
+	}
+	if err != nil {
+		return line, err
+	}
+	err = json.Unmarshal(data, &line)
+	return line, err
+}
+
+// due gives the time at which an event recorded t milliseconds in should be played.
+func (pl *Player) due(start time.Time, t int64) time.Time {
+	if pl.Speed <= 0 {
+		return start
+	}
+	return start.Add(time.Duration(float64(t) / pl.Speed * float64(time.Millisecond)))
+}
+
+// Play sends every recorded event to events, keeping the gaps between them.
+// Pause pauses and resumes playback, Step plays the next turn while paused,
+// Faster and Slower double and halve the speed, Quit and Kill stop. events is closed when playback ends.
+func (pl *Player) Play(events chan<- Event, keyPresses <-chan rune) {
+	defer pl.file.Close()
+	defer close(events)
+
+	start := time.Now()
+	turn := 0
+	// played is the recorded time of the last event sent
+	var played int64
+	paused, stepping := false, false
+	// restart moves the timeline so playback carries on from the last event sent
+	restart := func() {
+		start = time.Now()
+		if pl.Speed > 0 {
+			start = start.Add(-time.Duration(float64(played) / pl.Speed * float64(time.Millisecond)))
+		}
+	}
+
+	for {
+		line, err := pl.readLine()
+		if err == io.EOF {
+			return
+		} else if err != nil {
+			fmt.Fprintln(Messages, "Replay failed:", err)
+			return
+		}
+		if line.Event == nil {
+			continue
+		}
+		event, err := UnmarshalEvent(line.Event)
+		if err != nil {
+			fmt.Fprintln(Messages, "Replay failed:", err)
+			return
+		}
+
+	waitLoop:
+		for !stepping {
+			// a nil channel never fires, so nothing is played while paused
+			var wait <-chan time.Time
+			if !paused {
+				due := time.Until(pl.due(start, line.Time))
+				if due <= 0 {
+					break
+				}
+				wait = time.After(due)
+			}
+
+			select {
+			case <-wait:
+				break waitLoop
+			case key := <-keyPresses:
+				switch KeyAction(key) {
+				case Pause:
+					paused = !paused
+					if paused {
+						events <- StateChange{CompletedTurns: turn, NewState: Paused}
+					} else {
+						events <- StateChange{CompletedTurns: turn, NewState: Executing}
+						restart()
+					}
+				case Step:
+					stepping = paused
+				case Faster:
+					if pl.Speed > 0 {
+						pl.Speed *= 2
+						restart()
+					}
+					fmt.Fprintln(Messages, "Replay speed:", pl.Speed)
+				case Slower:
+					if pl.Speed > 0 {
+						pl.Speed /= 2
+					} else {
+						pl.Speed = 1
+					}
+					restart()
+					fmt.Fprintln(Messages, "Replay speed:", pl.Speed)
+				case Quit, Kill:
+					return
+				}
+			}
+		}
+
+		events <- event
+		turn = event.GetCompletedTurns()
+		played = line.Time
+		if _, ok := event.(TurnComplete); ok {
+			stepping = false
+		}
+	}
+}
@@ -3,10 +3,14 @@ package main
 import (
 	"flag"
 	"fmt"
+	"os"
 	"runtime"
 
 	"uk.ac.bris.cs/gameoflife/gol"
 	"uk.ac.bris.cs/gameoflife/sdl"
+	"uk.ac.bris.cs/gameoflife/term"
+	"uk.ac.bris.cs/gameoflife/util"
+	"uk.ac.bris.cs/gameoflife/web"
 )
 
 // main is the function called when starting Game of Life with 'go run .'
@@ -43,26 +47,180 @@ func main() {
 		false,
 		"Disables the SDL window, so there is no visualisation during the tests.")
 
+	vis := flag.String(
+		"vis",
+		"sdl",
+		"Where to show the board: sdl, terminal or none. -noVis is the same as none.")
+
+	flag.BoolVar(
+		&params.CellFlippedEvents,
+		"cellFlipped",
+		false,
+		"Sends a CellFlipped event for every changed cell instead of one CellsFlipped per worker per turn.")
+
+	record := flag.String(
+		"record",
+		"",
+		"Records every event to the given JSON Lines file.")
+
+	replay := flag.String(
+		"replay",
+		"",
+		"Replays a recording made with -record instead of running the simulation.")
+
+	speed := flag.Float64(
+		"speed",
+		1,
+		"Playback speed for -replay. 0 plays as fast as possible. Defaults to 1.")
+
+	metricsAddr := flag.String(
+		"metrics",
+		"",
+		"Serves Prometheus metrics on the given address, e.g. localhost:9100. Off by default.")
+
+	populationCSV := flag.String(
+		"population",
+		"",
+		"Writes the population to the given CSV file when the run ends. The workers compute several turns "+
+			"at a time, so there is a row for each frame the broker sends, which can be several turns apart.")
+
+	keysFile := flag.String(
+		"keys",
+		"",
+		"Reads key bindings from the given file, with a \"key = action\" on each line.")
+
+	webAddr := flag.String(
+		"web",
+		"",
+		"Serves a browser viewer on the given address, e.g. localhost:8080. Off by default.")
+
+	flag.StringVar(
+		&params.ServerDetails,
+		"broker",
+		"",
+		"Address of the broker. Defaults to $GOL_BROKER, then the broker line of gol.conf, then localhost:8030.")
+
+	flag.IntVar(
+		&params.Attach,
+		"attach",
+		0,
+		"Takes control of the detached job with the given ID on the broker, instead of starting a new one.")
+
 	flag.Parse()
 
-	fmt.Println("Threads:", params.Threads)
-	fmt.Println("Width:", params.ImageWidth)
-	fmt.Println("Height:", params.ImageHeight)
+	if *noVis {
+		*vis = "none"
+	}
+	if *vis != "sdl" && *vis != "terminal" && *vis != "none" {
+		fmt.Fprintln(os.Stderr, "Unknown -vis:", *vis)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *vis == "terminal" {
+		gol.Messages = os.Stderr
+	}
+
+	bindings := gol.DefaultBindings()
+	if *keysFile != "" {
+		var err error
+		bindings, err = gol.LoadBindings(*keysFile)
+		util.Check(err)
+	}
 
 	keyPresses := make(chan rune, 10)
 	events := make(chan gol.Event, 1000)
+	edits := make(chan gol.Edit, 100)
 
-	go gol.Run(params, events, keyPresses)
-	if !(*noVis) {
-		sdl.Run(params, events, keyPresses)
+	// Everything that wants to watch the run gets its own subscription to the hub
+	hub := gol.NewHub()
+	visEvents := hub.Subscribe(1000, gol.Coalesce)
+
+	if *replay != "" {
+		player, err := gol.NewPlayer(*replay)
+		util.Check(err)
+		player.Speed = *speed
+		params = player.Params
+		fmt.Println("Replaying:", *replay)
+		go player.Play(events, keyPresses)
+		// a recording can't be edited
+		edits = nil
+	} else {
+		if params.Attach != 0 {
+			var err error
+			params, err = gol.JobParams(params)
+			util.Check(err)
+			fmt.Println("Attaching to job", params.Attach)
+		}
+		fmt.Println("Threads:", params.Threads)
+		fmt.Println("Width:", params.ImageWidth)
+		fmt.Println("Height:", params.ImageHeight)
+		go gol.RunEditable(params, events, keyPresses, edits)
+	}
+
+	recorded := make(chan bool, 1)
+	if *record != "" && *replay == "" {
+		recorder, err := gol.NewRecorder(*record, params)
+		util.Check(err)
+		// The recording has to have every event, so it is allowed to hold up the simulation
+		recEvents := hub.Subscribe(1000, gol.Block)
+		go func() {
+			gol.Record(recorder, recEvents)
+			recorded <- true
+		}()
+	} else {
+		recorded <- true
+	}
+
+	populated := make(chan bool, 1)
+	if *populationCSV != "" {
+		// Every turn should be in the history, so like the recording it can hold up the simulation
+		populationEvents := hub.Subscribe(1000, gol.Block)
+		go func() {
+			err := gol.WritePopulation(*populationCSV, params, populationEvents)
+			if err != nil {
+				fmt.Fprintln(gol.Messages, "Writing population failed:", err)
+			}
+			populated <- true
+		}()
 	} else {
+		populated <- true
+	}
+
+	if *metricsAddr != "" {
+		// A stats endpoint can miss the odd event, but must never hold up the simulation
+		err := gol.ServeMetrics(*metricsAddr, hub.Subscribe(100, gol.Drop))
+		util.Check(err)
+	}
+
+	if *webAddr != "" {
+		// Browsers are sent the latest board when they catch up, so they can't hold up the simulation
+		err := web.Serve(*webAddr, params, hub.Subscribe(1000, gol.Coalesce), keyPresses)
+		util.Check(err)
+	}
+
+	go hub.Run(events)
+
+	switch *vis {
+	case "sdl":
+		sdl.Run(params, visEvents, keyPresses, edits, bindings)
+	case "terminal":
+		term.Run(params, visEvents, keyPresses, bindings)
+	default:
 		complete := false
 		for !complete {
-			event := <-events
+			event, ok := <-visEvents
+			if !ok {
+				break
+			}
 			switch event.(type) {
 			case gol.FinalTurnComplete:
 				complete = true
 			}
 		}
 	}
+
+	// The recording and population are only finished once the events channel has been closed
+	<-recorded
+	<-populated
 }
@@ -0,0 +1,77 @@
+package render
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+)
+
+// WritePNG saves a frame as a PNG, for making golden images.
+func WritePNG(filename string, frame image.Image) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	err = png.Encode(file, frame)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// ReadPNG loads a PNG, such as a golden image.
+func ReadPNG(filename string) (image.Image, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return png.Decode(file)
+}
+
+// Compare reports how a frame differs from a golden image, or gives nil if they match.
+// Pixels are compared in grey, so a golden image saved in colour still matches.
+func Compare(frame, golden image.Image) error {
+	if frame.Bounds().Size() != golden.Bounds().Size() {
+		return fmt.Errorf("frame is %v but golden image is %v", frame.Bounds().Size(), golden.Bounds().Size())
+	}
+	size := frame.Bounds().Size()
+	differ := 0
+	var first image.Point
+	for y := 0; y < size.Y; y++ {
+		for x := 0; x < size.X; x++ {
+			got := color.GrayModel.Convert(frame.At(frame.Bounds().Min.X+x, frame.Bounds().Min.Y+y)).(color.Gray)
+			want := color.GrayModel.Convert(golden.At(golden.Bounds().Min.X+x, golden.Bounds().Min.Y+y)).(color.Gray)
+			if got != want {
+				if differ == 0 {
+					first = image.Pt(x, y)
+				}
+				differ++
+			}
+		}
+	}
+	if differ > 0 {
+		return fmt.Errorf("%d pixels differ from the golden image, the first at %v", differ, first)
+	}
+	return nil
+}
+
+// CompareGolden compares a frame with a golden PNG file.
+// If update is set the golden file is written from the frame instead, for when the expected output has changed.
+func CompareGolden(frame image.Image, filename string, update bool) error {
+	if update {
+		return WritePNG(filename, frame)
+	}
+	golden, err := ReadPNG(filename)
+	if err != nil {
+		return err
+	}
+	err = Compare(frame, golden)
+	if err != nil {
+		return fmt.Errorf("%v: %v", filename, err)
+	}
+	return nil
+}
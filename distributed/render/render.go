@@ -0,0 +1,71 @@
+// Package render drives anything that can show the board from the event stream.
+// It needs no display, so the way the visualiser reacts to events can be checked headlessly
+// with a Framebuffer and golden images.
+package render
+
+import (
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// Renderer is something that can show the board, such as an SDL window or a Framebuffer.
+// Cells are flipped as events arrive, but nothing is shown until RenderFrame is called.
+type Renderer interface {
+	FlipPixel(x, y int)
+	RenderFrame()
+}
+
+// Ager is a Renderer that colours cells by their age, and so needs to know how many turns pass between frames.
+type Ager interface {
+	AgeCells(generations int)
+}
+
+// Visualiser drives a Renderer from events, the same way whatever the renderer is.
+type Visualiser struct {
+	Renderer Renderer
+	// Paused is set while execution is paused, when flips are shown as soon as they arrive
+	Paused bool
+	// Turns is the number of completed turns at the last frame
+	Turns int
+}
+
+// Update applies one event to the renderer, reporting whether it ended the run.
+// A frame is rendered on every TurnComplete, and on every flip while paused
+// as cells can be edited then, but no turns complete.
+func (v *Visualiser) Update(event gol.Event) bool {
+	switch e := event.(type) {
+	case gol.CellFlipped:
+		v.Renderer.FlipPixel(e.Cell.X, e.Cell.Y)
+		if v.Paused {
+			v.Renderer.RenderFrame()
+		}
+	case gol.CellsFlipped:
+		for _, cell := range e.Cells {
+			v.Renderer.FlipPixel(cell.X, cell.Y)
+		}
+		if v.Paused {
+			v.Renderer.RenderFrame()
+		}
+	case gol.TurnComplete:
+		// turns can be skipped when events have been coalesced, so cells age by however many passed
+		if ager, ok := v.Renderer.(Ager); ok {
+			ager.AgeCells(e.CompletedTurns - v.Turns)
+		}
+		v.Turns = e.CompletedTurns
+		v.Renderer.RenderFrame()
+	case gol.StateChange:
+		v.Paused = e.NewState == gol.Paused
+	case gol.FinalTurnComplete:
+		return true
+	}
+	return false
+}
+
+// Play drives a renderer from events until the run ends or events is closed.
+func Play(r Renderer, events <-chan gol.Event) {
+	v := &Visualiser{Renderer: r}
+	for event := range events {
+		if v.Update(event) {
+			return
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package render
+
+import (
+	"flag"
+	"path/filepath"
+	"testing"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+var update = flag.Bool("update", false, "write the golden images from the frames instead of comparing with them")
+
+func cells(xy ...int) []util.Cell {
+	var cells []util.Cell
+	for i := 0; i+1 < len(xy); i += 2 {
+		cells = append(cells, util.Cell{X: xy[i], Y: xy[i+1]})
+	}
+	return cells
+}
+
+func golden(t *testing.T, f *Framebuffer, name string) {
+	t.Helper()
+	err := CompareGolden(f.Frame(), filepath.Join("testdata", name+".png"), *update)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// TestVisualiser plays a glider through a Visualiser, checking that frames are only rendered
+// on TurnComplete, or straight away while paused, and that the last frame stays after FinalTurnComplete.
+func TestVisualiser(t *testing.T) {
+	f := NewFramebuffer(16, 16)
+	v := &Visualiser{Renderer: f}
+	play := func(events ...gol.Event) {
+		t.Helper()
+		for _, event := range events {
+			if v.Update(event) {
+				t.Fatalf("%T ended the run early", event)
+			}
+		}
+	}
+
+	play(gol.CellsFlipped{CompletedTurns: 0, Cells: cells(1, 0, 2, 1, 0, 2, 1, 2, 2, 2)})
+	if f.Rendered != 0 {
+		t.Fatalf("%d frames rendered before the first TurnComplete", f.Rendered)
+	}
+	golden(t, f, "empty")
+
+	play(gol.TurnComplete{CompletedTurns: 0})
+	golden(t, f, "glider0")
+
+	// the glider's next generation, which mustn't be seen until its turn completes
+	for _, cell := range cells(1, 0, 0, 2, 0, 1, 1, 3) {
+		play(gol.CellFlipped{CompletedTurns: 1, Cell: cell})
+	}
+	golden(t, f, "glider0")
+	play(gol.TurnComplete{CompletedTurns: 1})
+	golden(t, f, "glider1")
+
+	// edits while paused are shown as they are made
+	play(gol.StateChange{CompletedTurns: 1, NewState: gol.Paused},
+		gol.CellFlipped{CompletedTurns: 1, Cell: util.Cell{X: 10, Y: 10}})
+	golden(t, f, "edited")
+	play(gol.StateChange{CompletedTurns: 1, NewState: gol.Executing})
+
+	if !v.Update(gol.FinalTurnComplete{CompletedTurns: 1, Alive: cells(0, 1, 2, 1, 1, 2, 2, 2, 1, 3, 10, 10)}) {
+		t.Error("FinalTurnComplete didn't end the run")
+	}
+	golden(t, f, "edited")
+	if f.Rendered != 3 {
+		t.Errorf("%d frames rendered, want 3", f.Rendered)
+	}
+}
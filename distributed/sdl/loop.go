@@ -4,50 +4,89 @@ import (
 	"fmt"
 	"github.com/veandco/go-sdl2/sdl"
 	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/render"
 )
 
-func Run(p gol.Params, events <-chan gol.Event, keyPresses chan<- rune) {
+// Run shows the board until the events channel is closed, sending key presses for the actions
+// in bindings back to the engine. While execution is paused the board can be edited with the mouse
+// and patterns copied and pasted, unless edits is nil. Edits are only shown once the engine flips the cells.
+func Run(p gol.Params, events <-chan gol.Event, keyPresses chan<- rune, edits chan<- gol.Edit, bindings gol.Bindings) {
 	w := NewWindow(int32(p.ImageWidth), int32(p.ImageHeight))
+	w.SetHelp(bindings.Help())
+	vis := &render.Visualiser{Renderer: w}
+	ed := &editor{w: w, edits: edits}
+	h := newHud()
+	h.show(w, true)
+	history := gol.NewPopulationHistory(p)
+	w.ShowPopulation(history)
 
 sdlLoop:
 	for {
+		editing := vis.Paused && edits != nil
 		event := w.PollEvent()
 		if event != nil {
 			switch e := event.(type) {
 			case *sdl.KeyboardEvent:
-				switch e.Keysym.Sym {
-				case sdl.K_p:
-					keyPresses <- 'p'
-				case sdl.K_s:
-					keyPresses <- 's'
-				case sdl.K_q:
-					keyPresses <- 'q'
-				case sdl.K_k:
-					keyPresses <- 'k'
+				name := sdl.GetKeyName(e.Keysym.Sym)
+				if e.Keysym.Mod&sdl.KMOD_CTRL != 0 {
+					name = "ctrl+" + name
+				}
+				action := bindings.Action(name)
+				if key := action.Key(); key != 0 {
+					keyPresses <- key
+				}
+				switch action {
+				case gol.Fit:
+					w.FitToWindow()
+				case gol.Grid:
+					w.ToggleGrid()
+				case gol.Colours:
+					fmt.Println("Colours:", w.CycleColours())
+				case gol.Graph:
+					w.ToggleGraph()
+				case gol.Help:
+					w.ToggleHelp()
+				case gol.Copy, gol.Paste, gol.Rotate, gol.Mirror, gol.Cancel:
+					if editing {
+						ed.action(action)
+					}
+				}
+			default:
+				if !editing || !ed.mouse(event) {
+					w.HandleEvent(event)
 				}
 			}
 		}
+		if editing {
+			ed.send()
+		}
 		select {
 		case event, ok := <-events:
 			if !ok {
 				w.Destroy()
 				break sdlLoop
 			}
-			switch e := event.(type) {
-			case gol.CellFlipped:
-				w.FlipPixel(e.Cell.X, e.Cell.Y)
-			case gol.TurnComplete:
-				w.RenderFrame()
-			case gol.FinalTurnComplete:
+			h.update(event)
+			history.Update(event)
+			if vis.Update(event) {
 				w.Destroy()
 				break sdlLoop
+			}
+			if !vis.Paused {
+				ed.reset()
+			}
+			switch event.(type) {
+			case gol.CellFlipped, gol.CellsFlipped, gol.TurnComplete:
 			default:
 				if len(event.String()) > 0 {
 					fmt.Printf("Completed Turns %-8v%v\n", event.GetCompletedTurns(), event)
 				}
 			}
+			_, stateChanged := event.(gol.StateChange)
+			h.show(w, stateChanged)
 		default:
-			break
+			// catch up on anything held back while the title was being updated too often
+			h.show(w, false)
 		}
 	}
 
@@ -0,0 +1,52 @@
+package stubs
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// DefaultBroker is the broker's address when nothing else gives one.
+const DefaultBroker = "localhost:8030"
+
+// BrokerEnv is the environment variable that gives the broker's address, so each cluster on a host can have its own.
+const BrokerEnv = "GOL_BROKER"
+
+// ConfigFile is read from the working directory for the broker's address when BrokerEnv isn't set.
+// Each line is "name = value", # starts a comment, and the address is given by "broker".
+const ConfigFile = "gol.conf"
+
+// BrokerAddress gives the broker's address from BrokerEnv, then ConfigFile, falling back to DefaultBroker.
+func BrokerAddress() string {
+	if address := os.Getenv(BrokerEnv); address != "" {
+		return address
+	}
+	if address := readConfig(ConfigFile)["broker"]; address != "" {
+		return address
+	}
+	return DefaultBroker
+}
+
+// readConfig reads the settings in a config file, giving none if it can't be read.
+func readConfig(filename string) map[string]string {
+	settings := make(map[string]string)
+	file, err := os.Open(filename)
+	if err != nil {
+		return settings
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		text := scanner.Text()
+		if comment := strings.Index(text, "#"); comment >= 0 {
+			text = text[:comment]
+		}
+		parts := strings.SplitN(text, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		settings[strings.ToLower(strings.TrimSpace(parts[0]))] = strings.TrimSpace(parts[1])
+	}
+	return settings
+}
@@ -1,18 +1,47 @@
 package stubs
 
-import "uk.ac.bris.cs/gameoflife/util"
-
-var NodeStep = "GameOfLifeBoard.NextStep"
-var GameOfLifeHandler = "Broker.Increment"
-var Ticker = "Broker.Report"
+import (
+	"time"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+var NodeLoad = "GameOfLifeBoard.Load"
+var NodePeerStep = "GameOfLifeBoard.PeerStep"
+var NodeHalo = "GameOfLifeBoard.Halo"
+var NodeGather = "GameOfLifeBoard.Gather"
+var NodeDrop = "GameOfLifeBoard.Drop"
+var NodeRelink = "GameOfLifeBoard.Relink"
+var Submit = "Broker.Submit"
+var Poll = "Broker.Poll"
+var Result = "Broker.Result"
+var ListJobs = "Broker.ListJobs"
+var Detach = "Broker.Detach"
+var Attach = "Broker.Attach"
 var P = "Broker.KeyP"
 var Q = "Broker.KeyQ"
 var K = "Broker.KeyK"
 var S = "Broker.KeyS"
+var Metrics = "Broker.Metrics"
+var N = "Broker.KeyN"
+var Faster = "Broker.KeyFaster"
+var Slower = "Broker.KeySlower"
+var Edit = "Broker.Edit"
 
 var Shutdown = "GameOfLifeBoard.Shutdown"
 
 var Subscribe = "Broker.Subscribe"
+var Unsubscribe = "Broker.Unsubscribe"
+var Heartbeat = "Broker.Heartbeat"
+var ListWorkers = "Broker.ListWorkers"
+
+// NotSubscribed is the error given to a worker the broker doesn't know, which should subscribe again
+var NotSubscribed = "worker is not subscribed"
+
+// PeerFailed starts the error from a worker that couldn't swap edge rows with a neighbour
+var PeerFailed = "neighbour failed"
+
+// StripLost starts the error from a worker that doesn't have the strip asked for, which has to be loaded again
+var StripLost = "strip lost"
 
 type GameBoard struct {
 	World [][]uint8
@@ -38,20 +67,21 @@ type Topic struct {
 }
 
 
-type TickerRequest struct {
-
-}
-type TickerResponse struct {
-	Alive int
-	Turns int
-}
-
-
 type BoardResponse struct {
 	World [][]uint8
 	Alive []util.Cell
 	Turns int
 	Quitting bool
+	// Detached is set when the client detached before the job finished, so the board is from part way through
+	Detached bool
+}
+
+type AttachResponse struct {
+	World  [][]uint8
+	Turn   int
+	Params StubsParams
+	// Paused is set for a job left paused, which the new client has to resume
+	Paused bool
 }
 
 type BoardRequest struct {
@@ -59,6 +89,54 @@ type BoardRequest struct {
 	Params StubsParams
 }
 
+type SubmitResponse struct {
+	// Job identifies the job in the other calls
+	Job int
+}
+
+type PollRequest struct {
+	Job int
+	// After is the last turn the client has seen, only progress past it is given back
+	After int
+}
+type PollResponse struct {
+	Progress []Progress
+	// Finished is set once the job has no more turns to compute, and its board can be had from Result
+	Finished bool
+}
+
+// Progress is the state of a job after a round of turns
+type Progress struct {
+	Turn  int
+	Alive int
+	// Flipped are the cells that changed since the progress before, while a client is attached.
+	// Rounds the client hasn't collected yet are merged, so only the cells that end up changed are sent
+	Flipped []util.Cell
+}
+
+// JobRequest names a job, for calls that need nothing more
+type JobRequest struct {
+	Job int
+}
+
+type ListJobsRequest struct {
+
+}
+type ListJobsResponse struct {
+	Jobs []JobStatus
+}
+
+type JobStatus struct {
+	Job         int
+	ImageWidth  int
+	ImageHeight int
+	Turn        int
+	Turns       int
+	// State is running, paused, detached or finished
+	State       string
+	Started     time.Time
+}
+
 type IncrementRequest struct {
 	World [][]uint8
 	StartHeight  int
@@ -68,17 +146,118 @@ type IncrementRequest struct {
 	TopWrap      bool
 	BottomWrap   bool
 }
-type IncrementResponse struct {
+
+// LoadRequest gives a worker the strip of rows it keeps between turns
+type LoadRequest struct {
+	World        [][]uint8
+	StartHeight  int
+	EndHeight    int
+	Width        int
+	ActualHeight int
+	// Above and Below are the addresses of the workers holding the neighbouring strips
+	Above        string
+	Below        string
+	// Assignment counts the loads, so that edge rows left over from an earlier one can be told apart
+	Assignment   int
+	Turn         int
+	// Job is the job the strip belongs to, as a worker holds a strip for each job
+	Job          int
+	// CatchUp is how many turns to compute on loading, for a strip lost part way through a job.
+	// World then holds that many extra rows either side of the strip, and the strip is kept at Turn+CatchUp
+	CatchUp      int
+	// Wrap means World is instead the whole board, starting at the strip's first row, as it is smaller than those rows
+	Wrap         bool
+	// Diff asks for the cells that flipped since the turn DiffFrom
+	Diff         bool
+	DiffFrom     int
+}
+
+// LoadResponse gives the state of a strip once it has been loaded
+type LoadResponse struct {
+	// Alive counts the alive cells in the strip once it has caught up
+	Alive   int
+	Flipped []util.Cell
+}
+
+// RelinkRequest gives a strip kept at Turn new neighbours, after a strip next to it was lost
+type RelinkRequest struct {
+	Job        int
+	Turn       int
+	Above      string
+	Below      string
+	Assignment int
+}
+
+// HaloRow is an edge row sent between neighbouring workers
+type HaloRow struct {
+	Job        int
+	Assignment int
+	Turn       int
+	// FromAbove is set for the bottom rows of the strip above, which go above the receiver's strip
+	FromAbove  bool
+	Rows       [][]uint8
+}
+
+// PeerStepRequest has a worker compute turns of the strip it holds. Computing k turns at once
+// needs k halo rows from each neighbour, so that is the depth of the halos swapped
+type PeerStepRequest struct {
+	Job  int
+	Turn int
+	// Turns is how many turns to compute, which is also how many edge rows are swapped
+	Turns int
+	// Diff asks for the cells that flipped, for a client watching the board
+	Diff bool
+}
+type PeerStepResponse struct {
+	StepTime time.Duration
+	// Alive counts the alive cells in the strip after the turns
+	Alive int
+	// Flipped are the cells that differ from before the turns, when asked for
+	Flipped []util.Cell
+}
+
+type GatherResponse struct {
 	World [][]uint8
 }
 
+type MetricsRequest struct {
+	Job int
+}
+type MetricsResponse struct {
+	Turns           int
+	TurnsPerSecond  float64
+	WorkerStepTimes []time.Duration
+	SendTime        time.Duration
+}
+
 type KeyPRequest struct {
+	Job    int
 	Paused bool
 }
 type KeyPResponse struct {
 	Turn int
 }
 
+type KeyNResponse struct {
+	Turn int
+}
+
+type EditRequest struct {
+	Job   int
+	Cell  util.Cell
+	Alive bool
+}
+type EditResponse struct {
+	// Flipped is false if the cell was already in the state asked for
+	Flipped bool
+	Turn    int
+}
+
+type SpeedResponse struct {
+	// TurnsPerSecond is the new target speed, 0 means as fast as possible
+	TurnsPerSecond int
+}
+
 type KeySResponse struct {
 	World [][]uint8
 }
@@ -91,7 +270,7 @@ type KeyQResponse struct {
 }
 
 type KeyRequest struct {
-
+	Job int
 }
 
 type PublishRequest struct {
@@ -110,6 +289,21 @@ type SubscriptionRequest struct {
 	FactoryAddress string
 }
 
+type ListWorkersRequest struct {
+
+}
+type ListWorkersResponse struct {
+	Workers []WorkerStatus
+}
+
+type WorkerStatus struct {
+	Address  string
+	LastSeen time.Time
+	Steps    int
+	// StepTime is the average time the worker spent computing a strip
+	StepTime time.Duration
+}
+
 
 type NewWorld struct {
 	Result [][]uint8
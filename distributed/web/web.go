@@ -0,0 +1,317 @@
+// Package web lets a run be watched and controlled from a browser.
+// The board is streamed to the page as Server-Sent Events and key presses are posted back.
+package web
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// frameInterval is how often changes to the board are sent to browsers at most.
+const frameInterval = 50 * time.Millisecond
+
+// clientBuffer is how many messages a browser can fall behind by before it is sent the whole board again.
+const clientBuffer = 64
+
+// board is sent when a browser first connects, or when it has fallen too far behind.
+type board struct {
+	Width  int   `json:"width"`
+	Height int   `json:"height"`
+	Turn   int   `json:"turn"`
+	Alive  []int `json:"alive"`
+}
+
+// diff holds the cells flipped since the last one, as x, y pairs.
+type diff struct {
+	Turn    int   `json:"turn"`
+	Flipped []int `json:"flipped"`
+}
+
+type stats struct {
+	Turn           int     `json:"turn"`
+	Alive          int     `json:"alive"`
+	TurnsPerSecond float64 `json:"turnsPerSecond"`
+	State          string  `json:"state"`
+	Rule           string  `json:"rule"`
+}
+
+type client struct {
+	messages chan string
+	// resync is set when a message could not be sent, so the whole board has to be sent instead
+	resync bool
+}
+
+type server struct {
+	mutex      *sync.Mutex
+	width      int
+	height     int
+	alive      []bool
+	population int
+	turns      int
+	rate       float64
+	state      gol.State
+	ended      bool
+	flipped    map[util.Cell]bool
+	sent       time.Time
+	clients    map[*client]bool
+	keyPresses chan<- rune
+	// token is put in the page and has to come back with each key press
+	token string
+}
+
+// Serve starts an HTTP server on addr showing the run described by events, and sends key presses from the page on to keyPresses.
+// A missing host is taken as localhost, so use e.g. 0.0.0.0:8080 to share the run on the network.
+func Serve(addr string, p gol.Params, events <-chan gol.Event, keyPresses chan<- rune) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if host == "" {
+		host = "localhost"
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return err
+	}
+	token := make([]byte, 16)
+	_, err = rand.Read(token)
+	if err != nil {
+		listener.Close()
+		return err
+	}
+
+	s := &server{
+		mutex:      &sync.Mutex{},
+		width:      p.ImageWidth,
+		height:     p.ImageHeight,
+		alive:      make([]bool, p.ImageWidth*p.ImageHeight),
+		state:      gol.Executing,
+		flipped:    make(map[util.Cell]bool),
+		clients:    make(map[*client]bool),
+		keyPresses: keyPresses,
+		token:      hex.EncodeToString(token),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", s.page)
+	mux.HandleFunc("/events", s.stream)
+	mux.HandleFunc("/key", s.key)
+	go http.Serve(listener, mux)
+	go s.watch(events)
+	fmt.Println("Viewer on http://" + listener.Addr().String() + "/")
+	return nil
+}
+
+func (s *server) watch(events <-chan gol.Event) {
+	for event := range events {
+		s.mutex.Lock()
+		switch e := event.(type) {
+		case gol.CellFlipped:
+			s.flip(e.Cell)
+		case gol.CellsFlipped:
+			for _, cell := range e.Cells {
+				s.flip(cell)
+			}
+		case gol.TurnComplete:
+			s.turns = e.CompletedTurns
+		case gol.StateChange:
+			s.turns = e.CompletedTurns
+			s.state = e.NewState
+		case gol.Metrics:
+			s.rate = e.TurnsPerSecond
+		case gol.FinalTurnComplete:
+			s.turns = e.CompletedTurns
+		}
+
+		// while paused there are no turns, but edits should still show up
+		_, turn := event.(gol.TurnComplete)
+		_, state := event.(gol.StateChange)
+		if state || ((turn || s.state == gol.Paused) && time.Since(s.sent) >= frameInterval) {
+			s.broadcast()
+		}
+		s.mutex.Unlock()
+	}
+
+	s.mutex.Lock()
+	s.ended = true
+	s.broadcast()
+	for c := range s.clients {
+		close(c.messages)
+	}
+	s.clients = nil
+	s.mutex.Unlock()
+}
+
+func (s *server) flip(cell util.Cell) {
+	i := cell.Y*s.width + cell.X
+	s.alive[i] = !s.alive[i]
+	if s.alive[i] {
+		s.population++
+	} else {
+		s.population--
+	}
+	// a cell flipped twice since the last frame doesn't need sending
+	if s.flipped[cell] {
+		delete(s.flipped, cell)
+	} else {
+		s.flipped[cell] = true
+	}
+}
+
+func message(event string, data interface{}) string {
+	encoded, err := json.Marshal(data)
+	util.Check(err)
+	return "event: " + event + "\ndata: " + string(encoded) + "\n\n"
+}
+
+func (s *server) boardMessage() string {
+	b := board{Width: s.width, Height: s.height, Turn: s.turns, Alive: []int{}}
+	for i, alive := range s.alive {
+		if alive {
+			b.Alive = append(b.Alive, i%s.width, i/s.width)
+		}
+	}
+	return message("board", b)
+}
+
+func (s *server) statsMessage() string {
+	state := s.state.String()
+	if s.ended {
+		state = "Finished"
+	}
+	return message("stats", stats{
+		Turn:           s.turns,
+		Alive:          s.population,
+		TurnsPerSecond: s.rate,
+		State:          state,
+		Rule:           gol.Rule,
+	})
+}
+
+// broadcast sends every browser the cells flipped since the last broadcast and the latest stats.
+func (s *server) broadcast() {
+	d := diff{Turn: s.turns, Flipped: make([]int, 0, 2*len(s.flipped))}
+	for cell := range s.flipped {
+		d.Flipped = append(d.Flipped, cell.X, cell.Y)
+	}
+	diffMessage := message("diff", d)
+	statsMessage := s.statsMessage()
+
+	var boardMessage string
+	for c := range s.clients {
+		if c.resync {
+			if boardMessage == "" {
+				boardMessage = s.boardMessage()
+			}
+			c.resync = !c.send(boardMessage)
+		} else if len(d.Flipped) > 0 {
+			c.resync = !c.send(diffMessage)
+		}
+		if !c.resync {
+			c.resync = !c.send(statsMessage)
+		}
+	}
+	s.flipped = make(map[util.Cell]bool)
+	s.sent = time.Now()
+}
+
+// send gives a message to a browser without waiting, reporting whether there was room for it.
+func (c *client) send(message string) bool {
+	select {
+	case c.messages <- message:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *server) stream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	c := &client{messages: make(chan string, clientBuffer)}
+	s.mutex.Lock()
+	c.send(s.boardMessage())
+	c.send(s.statsMessage())
+	if s.ended {
+		close(c.messages)
+	} else {
+		s.clients[c] = true
+	}
+	s.mutex.Unlock()
+
+	defer func() {
+		s.mutex.Lock()
+		delete(s.clients, c)
+		s.mutex.Unlock()
+	}()
+
+	for {
+		select {
+		case m, ok := <-c.messages:
+			if !ok {
+				return
+			}
+			_, err := fmt.Fprint(w, m)
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// key takes a key press posted from the page. The page always uses the keys Run understands.
+// Other sites can post here from the user's browser too, but can't read the page for its token.
+func (s *server) key(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(r.FormValue("token")), []byte(s.token)) != 1 {
+		http.Error(w, "missing or wrong token", http.StatusForbidden)
+		return
+	}
+	key := []rune(r.FormValue("key"))
+	if len(key) != 1 {
+		http.Error(w, "key should be a single character", http.StatusBadRequest)
+		return
+	}
+	if gol.KeyAction(key[0]) == gol.NoAction {
+		http.Error(w, "unknown key", http.StatusBadRequest)
+		return
+	}
+	// the run may be busy or over, and the request shouldn't hang waiting for it
+	select {
+	case s.keyPresses <- key[0]:
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "the run isn't taking key presses", http.StatusServiceUnavailable)
+	}
+}
+
+func (s *server) page(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, strings.Replace(page, "{{token}}", s.token, 1))
+}
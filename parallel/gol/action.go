@@ -0,0 +1,174 @@
+package gol
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Action is something the user can ask for with a key.
+// Engine actions are sent to Run as key presses, the others are handled by the visualiser itself.
+type Action int
+
+const (
+	NoAction Action = iota
+	Pause
+	Snapshot
+	Quit
+	Kill
+	Step
+	Faster
+	Slower
+	// Visualiser actions never reach the engine.
+	Fit
+	Grid
+	Colours
+	Graph
+	Help
+	// Copy, Paste, Rotate, Mirror and Cancel work on patterns while paused.
+	Copy
+	Paste
+	Rotate
+	Mirror
+	Cancel
+)
+
+var actionNames = []string{"none", "pause", "snapshot", "quit", "kill", "step", "faster", "slower",
+	"fit", "grid", "colours", "graph", "help", "copy", "paste", "rotate", "mirror", "cancel"}
+
+// actionKeys are the key presses Run understands, indexed by Action.
+var actionKeys = []rune{0, 'p', 's', 'q', 'k', 'n', '+', '-'}
+
+func (action Action) String() string {
+	if action < 0 || int(action) >= len(actionNames) {
+		return "unknown"
+	}
+	return actionNames[action]
+}
+
+// Key gives the key press to send to Run for an engine action, or 0 for any other action.
+func (action Action) Key() rune {
+	if action < 0 || int(action) >= len(actionKeys) {
+		return 0
+	}
+	return actionKeys[action]
+}
+
+// KeyAction gives the engine action for a key press sent to Run, or NoAction if there isn't one.
+func KeyAction(key rune) Action {
+	for action, actionKey := range actionKeys {
+		if key != 0 && key == actionKey {
+			return Action(action)
+		}
+	}
+	return NoAction
+}
+
+// Bindings maps the names of keys to the actions they do. Key names are lower case,
+// single characters for character keys and SDL's names, such as "space" or "keypad +", for the rest.
+// Keys held with control are named with "ctrl+" in front.
+type Bindings map[string]Action
+
+func DefaultBindings() Bindings {
+	return Bindings{
+		"p":        Pause,
+		"s":        Snapshot,
+		"q":        Quit,
+		"k":        Kill,
+		"n":        Step,
+		"=":        Faster,
+		"+":        Faster,
+		"keypad +": Faster,
+		"-":        Slower,
+		"keypad -": Slower,
+		"f":        Fit,
+		"g":        Grid,
+		"c":        Colours,
+		"v":        Graph,
+		"h":        Help,
+		"ctrl+c":   Copy,
+		"ctrl+v":   Paste,
+		"r":        Rotate,
+		"m":        Mirror,
+		"escape":   Cancel,
+	}
+}
+
+// LoadBindings reads a keybinding file on top of the default bindings.
+// Each line binds a key to an action as "key = action", and # starts a comment.
+// Binding an action in the file removes its default keys, so they can be used for something else,
+// and binding a key to "none" just removes it.
+func LoadBindings(filename string) (Bindings, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	bindings := DefaultBindings()
+	rebound := make(map[Action]bool)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if comment := strings.Index(text, "#"); comment >= 0 {
+			text = text[:comment]
+		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		// the key is split off at the last '=', so that '=' itself can be bound
+		equals := strings.LastIndex(text, "=")
+		if equals < 0 {
+			return nil, fmt.Errorf("%v:%d: expected key = action", filename, line)
+		}
+		key := strings.ToLower(strings.TrimSpace(text[:equals]))
+		name := strings.ToLower(strings.TrimSpace(text[equals+1:]))
+		action := NoAction
+		for i, actionName := range actionNames {
+			if name == actionName {
+				action = Action(i)
+			}
+		}
+		if key == "" || (action == NoAction && name != "none") {
+			return nil, fmt.Errorf("%v:%d: cannot bind %q to %q", filename, line, key, name)
+		}
+
+		if !rebound[action] {
+			for k, a := range bindings {
+				if a == action {
+					delete(bindings, k)
+				}
+			}
+			rebound[action] = true
+		}
+		delete(bindings, key)
+		if action != NoAction {
+			bindings[key] = action
+		}
+	}
+	return bindings, scanner.Err()
+}
+
+// Action gives the action bound to a key name, ignoring case.
+func (bindings Bindings) Action(key string) Action {
+	return bindings[strings.ToLower(key)]
+}
+
+// Help lists each action with the keys bound to it.
+func (bindings Bindings) Help() []string {
+	keys := make(map[Action][]string)
+	for key, action := range bindings {
+		keys[action] = append(keys[action], key)
+	}
+	var lines []string
+	for action := Pause; int(action) < len(actionNames); action++ {
+		if len(keys[action]) == 0 {
+			continue
+		}
+		sort.Strings(keys[action])
+		lines = append(lines, fmt.Sprintf("%-9v %v", action, strings.Join(keys[action], ", ")))
+	}
+	return lines
+}
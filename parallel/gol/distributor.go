@@ -16,6 +16,8 @@ type distributorChannels struct {
 	ioOutput   chan<- uint8
 	ioInput    <-chan uint8
 	ioKeyPress <-chan rune
+	edits      <-chan Edit
+	metrics    *metrics
 }
 
 type dimentions struct {
@@ -37,6 +39,10 @@ type keyChannels struct {
 	world   chan gameBoard
 	mutex   *sync.Mutex
 	pauseNo int
+	// step asks calculateNextState to run one turn while paused, stepped gives back the result
+	step     chan bool
+	stepped  chan gameBoard
+	throttle *throttle
 }
 
 func checkNeighbours(x int, y int, world [][]uint8, dim dimentions) uint8 {
@@ -85,25 +91,40 @@ func checkNeighbours(x int, y int, world [][]uint8, dim dimentions) uint8 {
 	return 0
 }
 
-func calculateSlice(dim dimentions, worldChan chan [][]uint8, channel chan [][]uint8, e chan<- Event) {
+func calculateSlice(p Params, worker int, dim dimentions, worldChan chan [][]uint8, channel chan [][]uint8, e chan<- Event, m *metrics) {
 	world := <-worldChan
 	turn := 0
 
 	for world != nil {
+		start := time.Now()
 		newWorld := make([][]uint8, dim.endHeight-dim.startHeight)
+		var flipped []util.Cell
 		for y := 1; y < dim.endHeight-dim.startHeight+1; y++ {
 			row := make([]uint8, dim.width)
 			for x := 0; x < dim.width; x++ {
 				k := checkNeighbours(x, y, world, dim)
 				if world[y][x] != k {
-					e <- CellFlipped{turn, util.Cell{X: x, Y: y + dim.startHeight-1}}
+					cell := util.Cell{X: x, Y: y + dim.startHeight - 1}
+					if p.CellFlippedEvents {
+						e <- CellFlipped{turn, cell}
+					} else {
+						flipped = append(flipped, cell)
+					}
 				}
 				row[x] = k
 			}
 			newWorld[y-1] = row
 
 		}
+		m.step(worker, time.Since(start))
+
+		start = time.Now()
+		// one event per worker per turn keeps the events channel from becoming the bottleneck
+		if len(flipped) > 0 {
+			e <- CellsFlipped{turn, flipped}
+		}
 		channel <- newWorld
+		m.send(time.Since(start))
 		world = <-worldChan
 		turn++
 	}
@@ -135,7 +156,7 @@ func calculateNextState(p Params, world [][]uint8, d distributorChannels, ticker
 		} else {
 			dim = dimentions{i * workerHeight, h, w, p.ImageHeight, false, false}
 		}
-		go calculateSlice(dim, sendWorld[i], out[i], d.events)
+		go calculateSlice(p, i, dim, sendWorld[i], out[i], d.events, d.metrics)
 	}
 
 	turn := 1
@@ -143,6 +164,10 @@ func calculateNextState(p Params, world [][]uint8, d distributorChannels, ticker
 	for ; turn <= p.Turns; turn++ {
 		var newWorld [][]uint8
 		var reducedWorld [][]uint8
+		kc.throttle.wait()
+		// the mutex is held for the whole turn, so pausing always happens between turns
+		mutex.Lock()
+		start := time.Now()
 		for i := 0; i < p.Threads; i++ {
 			h := i*workerHeight + workerHeight
 
@@ -161,6 +186,7 @@ func calculateNextState(p Params, world [][]uint8, d distributorChannels, ticker
 			}
 			sendWorld[i] <- reducedWorld
 		}
+		d.metrics.send(time.Since(start))
 		for i:=0; i < p.Threads; i++ {
 			newWorld = append(newWorld, <-out[i]...)
 		}
@@ -168,12 +194,23 @@ func calculateNextState(p Params, world [][]uint8, d distributorChannels, ticker
 		if len(kc.world) > 0 {
 			<-kc.world
 		}
-		mutex.Lock()
+		// only the latest board matters to reportAlive, and a full channel would block while holding the mutex
+		if len(tickerChan) > 0 {
+			<-tickerChan
+		}
+		start = time.Now()
 		kc.world <- gameBoard{world: world, turns: turn}
 		tickerChan <- gameBoard{world: world, turns: turn}
 		d.events <- TurnComplete{turn}
-		mutex.Unlock()
+		d.metrics.send(time.Since(start))
 
+		select {
+		case <-kc.step:
+			// keep the mutex locked and hand it straight back to keypress, so execution stays paused
+			kc.stepped <- gameBoard{world: world, turns: turn}
+		default:
+			mutex.Unlock()
+		}
 	}
 	return gameBoard{world, turn}
 }
@@ -206,6 +243,7 @@ func reportAlive(p Params, gb chan gameBoard, d distributorChannels, mutex *sync
 
 			mutex.Lock()
 			d.events <- AliveCellsCount{world.turns, len(alive)}
+			d.events <- d.metrics.report(world.turns)
 			mutex.Unlock()
 		case c := <-done:
 			flag = c
@@ -220,20 +258,46 @@ func reportAlive(p Params, gb chan gameBoard, d distributorChannels, mutex *sync
 // keypresses
 func keypress(c distributorChannels, p Params, fileName string, kc keyChannels) {
 	for {
-		switch <-c.ioKeyPress {
-		case 'p':
+		switch KeyAction(<-c.ioKeyPress) {
+		case Pause:
 			kc.mutex.Lock()
 			world := <-kc.world
 			c.events <- StateChange{CompletedTurns: world.turns, NewState: Paused}
 
-			for <-c.ioKeyPress != 'p' {
+			for paused := true; paused; {
+				select {
+				case key := <-c.ioKeyPress:
+					switch KeyAction(key) {
+					case Pause:
+						paused = false
+					case Step:
+						// let exactly one turn through, calculateNextState locks the mutex again for us
+						kc.throttle.resume()
+						kc.step <- true
+						kc.mutex.Unlock()
+						world = <-kc.stepped
+						fmt.Fprintln(Messages, "Current turn", world.turns)
+					}
+				case edit := <-c.edits:
+					applyEdit(c, p, world, edit)
+				}
+			}
+			// edits made before the key was pressed may still be queued, and belong to this turn
+			for queued := true; queued; {
+				select {
+				case edit := <-c.edits:
+					applyEdit(c, p, world, edit)
+				default:
+					queued = false
+				}
 			}
 
-			fmt.Println("Continuing")
+			fmt.Fprintln(Messages, "Continuing")
 			c.events <- StateChange{CompletedTurns: world.turns, NewState: Executing}
+			kc.throttle.resume()
 			kc.mutex.Unlock()
 
-		case 'q':
+		case Quit:
 
 			world := <-kc.world
 			kc.mutex.Lock()
@@ -244,14 +308,35 @@ func keypress(c distributorChannels, p Params, fileName string, kc keyChannels)
 
 			c.events <- StateChange{world.turns, Quitting}
 			close(c.events)
-		case 's':
+		case Snapshot:
 			outputFile(fileName, c, p, (<-kc.world).world)
-		case 'k':
+		case Kill:
 			// not used for parallel
+		case Faster:
+			fmt.Fprintln(Messages, "Speed:", speedString(kc.throttle.faster()))
+		case Slower:
+			fmt.Fprintln(Messages, "Speed:", speedString(kc.throttle.slower()))
 		}
 	}
 }
 
+// applyEdit changes a cell on the paused board. The board's rows are shared with
+// calculateNextState, so the change is picked up when execution resumes.
+func applyEdit(c distributorChannels, p Params, world gameBoard, edit Edit) {
+	x, y := edit.Cell.X, edit.Cell.Y
+	if x < 0 || y < 0 || x >= p.ImageWidth || y >= p.ImageHeight {
+		return
+	}
+	var value uint8
+	if edit.Alive {
+		value = 255
+	}
+	if world.world[y][x] != value {
+		world.world[y][x] = value
+		c.events <- CellFlipped{CompletedTurns: world.turns, Cell: edit.Cell}
+	}
+}
+
 // distributor divides the work between workers and interacts with other goroutines.
 func distributor(p Params, c distributorChannels) {
 
@@ -262,10 +347,13 @@ func distributor(p Params, c distributorChannels) {
 	var mutex = sync.Mutex{}
 
 	kc := keyChannels{
-		pause:   make(chan bool, 2),
-		world:   make(chan gameBoard, p.Threads+1),
-		mutex:   &mutex,
-		pauseNo: 0,
+		pause:    make(chan bool, 2),
+		world:    make(chan gameBoard, p.Threads+1),
+		mutex:    &mutex,
+		pauseNo:  0,
+		step:     make(chan bool, 1),
+		stepped:  make(chan gameBoard),
+		throttle: newThrottle(),
 	}
 
 	go keypress(c, p, filename, kc)
@@ -308,17 +396,25 @@ func inputFile(filename string, c distributorChannels, p Params) [][]uint8 {
 
 	// stores the starting state of the world
 	world := make([][]uint8, p.ImageHeight)
+	var alive []util.Cell
 	for y := 0; y < p.ImageHeight; y++ {
 		row := make([]uint8, p.ImageWidth)
 		for x := 0; x < p.ImageWidth; x++ {
 			row[x] = <-c.ioInput
 			// send a cell fliped event
 			if row[x] != 0 {
-				c.events <- CellFlipped{CompletedTurns: 0, Cell: util.Cell{X: x, Y: y}}
+				if p.CellFlippedEvents {
+					c.events <- CellFlipped{CompletedTurns: 0, Cell: util.Cell{X: x, Y: y}}
+				} else {
+					alive = append(alive, util.Cell{X: x, Y: y})
+				}
 			}
 		}
 		world[y] = row
 	}
+	if len(alive) > 0 {
+		c.events <- CellsFlipped{CompletedTurns: 0, Cells: alive}
+	}
 	return world
 }
 
@@ -0,0 +1,97 @@
+package gol
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// pauseUntil has keypress pause on the given board, sends the edits queued along with the key to resume,
+// and gives back the cells flipped before execution carried on.
+func pauseUntil(t *testing.T, keys chan rune, edits chan Edit, events chan Event, kc keyChannels, board gameBoard, queued []Edit) []util.Cell {
+	t.Helper()
+	keys <- 'p'
+	kc.world <- board
+	if e := next(t, events); e != (StateChange{CompletedTurns: board.turns, NewState: Paused}) {
+		t.Fatalf("got %v on pausing, want Paused", e)
+	}
+	// the edits and the key to resume are both waiting, so either may be picked first
+	for _, edit := range queued {
+		edits <- edit
+	}
+	keys <- 'p'
+
+	var flipped []util.Cell
+	for {
+		switch e := next(t, events).(type) {
+		case CellFlipped:
+			flipped = append(flipped, e.Cell)
+		case StateChange:
+			if e.NewState != Executing {
+				t.Fatalf("got %v on resuming, want Executing", e)
+			}
+			// keypress unlocks the mutex as it resumes
+			kc.mutex.Lock()
+			kc.mutex.Unlock()
+			return flipped
+		}
+	}
+}
+
+func next(t *testing.T, events chan Event) Event {
+	t.Helper()
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("no event from keypress")
+		return nil
+	}
+}
+
+// TestPauseEdits checks that the edits still queued when execution resumes are applied to the paused turn,
+// and not replayed on a later board at the next pause.
+func TestPauseEdits(t *testing.T) {
+	p := Params{ImageWidth: 16, ImageHeight: 16}
+	keys := make(chan rune, 10)
+	edits := make(chan Edit, 100)
+	events := make(chan Event, 100)
+	c := distributorChannels{events: events, ioKeyPress: keys, edits: edits}
+	kc := keyChannels{
+		world:    make(chan gameBoard, 1),
+		mutex:    &sync.Mutex{},
+		step:     make(chan bool, 1),
+		stepped:  make(chan gameBoard),
+		throttle: newThrottle(),
+	}
+	go keypress(c, p, "", kc)
+
+	board := func(turns int) gameBoard {
+		world := make([][]uint8, p.ImageHeight)
+		for y := range world {
+			world[y] = make([]uint8, p.ImageWidth)
+		}
+		return gameBoard{world: world, turns: turns}
+	}
+	first := board(1)
+	queued := make([]Edit, 20)
+	for i := range queued {
+		queued[i] = Edit{Cell: util.Cell{X: i % p.ImageWidth, Y: i / p.ImageWidth}, Alive: true}
+	}
+	flipped := pauseUntil(t, keys, edits, events, kc, first, queued)
+	if len(flipped) != len(queued) {
+		t.Errorf("%d of %d queued edits applied before resuming", len(flipped), len(queued))
+	}
+	for _, edit := range queued {
+		if first.world[edit.Cell.Y][edit.Cell.X] == 0 {
+			t.Errorf("edit at %v missing from the paused board", edit.Cell)
+		}
+	}
+
+	later := board(5)
+	if flipped := pauseUntil(t, keys, edits, events, kc, later, nil); len(flipped) != 0 {
+		t.Errorf("%d edits replayed at the next pause", len(flipped))
+	}
+}
@@ -1,15 +1,44 @@
 package gol
 
+import (
+	"io"
+	"os"
+
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// Messages is where messages for the user are written while a board runs.
+// The terminal visualiser draws over stdout, so main has them written to stderr instead.
+var Messages io.Writer = os.Stdout
+
 // Params provides the details of how to run the Game of Life and which image to load.
 type Params struct {
 	Turns       int
 	Threads     int
 	ImageWidth  int
 	ImageHeight int
+	// CellFlippedEvents sends a CellFlipped for every changed cell, as the tests expect,
+	// instead of one CellsFlipped per worker per turn.
+	CellFlippedEvents bool
+}
+
+// Rule is the rule being simulated, in birth/survival notation.
+const Rule = "B3/S23"
+
+// Edit sets a single cell on the board. Edits are only applied while execution is paused.
+type Edit struct {
+	Cell  util.Cell
+	Alive bool
 }
 
 // Run starts the processing of Game of Life. It should initialise channels and goroutines.
 func Run(p Params, events chan<- Event, keyPresses <-chan rune) {
+	RunEditable(p, events, keyPresses, nil)
+}
+
+// RunEditable is Run, but also applies any edits made to the board while execution is paused.
+// A CellFlipped event is sent for every cell an edit changes.
+func RunEditable(p Params, events chan<- Event, keyPresses <-chan rune, edits <-chan Edit) {
 
 	ioFilename := make(chan string, 2)
 	ioOutput := make(chan uint8, p.ImageWidth*p.ImageHeight)
@@ -25,7 +54,8 @@ func Run(p Params, events chan<- Event, keyPresses <-chan rune) {
 		output:   ioOutput,
 		input:    ioInput,
 	}
-	go startIo(p, ioChannels)
+	m := newMetrics(p.Threads)
+	go startIo(p, ioChannels, m)
 
 	distributorChannels := distributorChannels{
 		events:     events,
@@ -35,6 +65,8 @@ func Run(p Params, events chan<- Event, keyPresses <-chan rune) {
 		ioOutput:   ioOutput,
 		ioInput:    ioInput,
 		ioKeyPress: keyPresses,
+		edits:      edits,
+		metrics:    m,
 	}
 	distributor(p, distributorChannels)
 }
@@ -0,0 +1,100 @@
+package gol
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// maxRate is the fastest target speed. Going faster than this removes the limit altogether.
+const maxRate = 1024
+
+// catchUp is how far behind the target speed the throttle can fall before it gives up on those turns.
+const catchUp = 100 * time.Millisecond
+
+// throttle holds the simulation to a target number of turns per second.
+type throttle struct {
+	mutex *sync.Mutex
+	// rate is the target turns per second, 0 means as fast as possible
+	rate int
+	// next is when the next turn is due, kept as a running deadline so oversleeping is made up later
+	next time.Time
+	last time.Time
+	// interval is a running average of the time between turns, used to pick a first target
+	interval time.Duration
+}
+
+func newThrottle() *throttle {
+	return &throttle{mutex: &sync.Mutex{}, last: time.Now()}
+}
+
+// wait blocks until the next turn is allowed to start.
+func (t *throttle) wait() {
+	t.mutex.Lock()
+	now := time.Now()
+	var due time.Time
+	if t.rate > 0 {
+		gap := time.Second / time.Duration(t.rate)
+		t.next = t.next.Add(gap)
+		// catch up on small oversleeps, but not on turns lost while paused or after slowing down
+		if t.next.Before(now.Add(-catchUp)) || t.next.After(now.Add(gap)) {
+			t.next = now
+		}
+		due = t.next
+	}
+	t.mutex.Unlock()
+
+	time.Sleep(time.Until(due))
+
+	t.mutex.Lock()
+	now = time.Now()
+	t.interval = (3*t.interval + now.Sub(t.last)) / 4
+	t.last = now
+	t.mutex.Unlock()
+}
+
+// resume is called as a paused run carries on, so that the time spent paused isn't taken for a slow turn.
+func (t *throttle) resume() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.last = time.Now()
+}
+
+// slower halves the target speed, starting from half the current speed if there was no limit.
+func (t *throttle) slower() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.rate == 0 {
+		t.rate = maxRate
+		if t.interval > 0 && time.Second/t.interval < maxRate {
+			t.rate = int(time.Second / t.interval)
+		}
+	}
+	t.rate /= 2
+	if t.rate < 1 {
+		t.rate = 1
+	}
+	return t.rate
+}
+
+// faster doubles the target speed, removing the limit once it goes past maxRate.
+func (t *throttle) faster() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.rate == 0 {
+		return 0
+	}
+	t.rate *= 2
+	if t.rate > maxRate {
+		t.rate = 0
+	}
+	return t.rate
+}
+
+// speedString describes a target speed for printing.
+func speedString(rate int) string {
+	if rate == 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%d turns/s", rate)
+}
@@ -0,0 +1,65 @@
+package render
+
+import (
+	"fmt"
+	"image"
+)
+
+// Framebuffer is a Renderer that keeps its frames in memory instead of showing them.
+// Alive cells are white and dead cells black in each frame, as in the SDL window's plain colours.
+type Framebuffer struct {
+	Width, Height int
+	// Frames holds every frame rendered, oldest first, if KeepFrames is set
+	Frames     []*image.Gray
+	KeepFrames bool
+	// Rendered counts the frames rendered
+	Rendered int
+
+	pixels *image.Gray
+	frame  *image.Gray
+}
+
+func NewFramebuffer(width, height int) *Framebuffer {
+	return &Framebuffer{
+		Width:  width,
+		Height: height,
+		pixels: image.NewGray(image.Rect(0, 0, width, height)),
+		frame:  image.NewGray(image.Rect(0, 0, width, height)),
+	}
+}
+
+// FlipPixel flips a cell. Like the SDL window, it panics if the cell is outside the board.
+func (f *Framebuffer) FlipPixel(x, y int) {
+	if x < 0 || y < 0 || x >= f.Width || y >= f.Height {
+		panic(fmt.Sprintf("CellFlipped event at (%d, %d) is outside the bounds of the window.", x, y))
+	}
+	i := f.pixels.PixOffset(x, y)
+	f.pixels.Pix[i] = ^f.pixels.Pix[i]
+}
+
+// RenderFrame makes the cells flipped so far visible in Frame.
+func (f *Framebuffer) RenderFrame() {
+	copy(f.frame.Pix, f.pixels.Pix)
+	f.Rendered++
+	if f.KeepFrames {
+		frame := image.NewGray(f.frame.Rect)
+		copy(frame.Pix, f.frame.Pix)
+		f.Frames = append(f.Frames, frame)
+	}
+}
+
+// Frame gives the last frame rendered, which is all black before the first one.
+func (f *Framebuffer) Frame() *image.Gray {
+	return f.frame
+}
+
+// CountPixels counts the alive cells, including any flipped since the last frame.
+func (f *Framebuffer) CountPixels() int {
+	count := 0
+	for _, pixel := range f.pixels.Pix {
+		if pixel == 0xFF {
+			count++
+		}
+	}
+	return count
+}
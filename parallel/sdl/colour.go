@@ -0,0 +1,94 @@
+package sdl
+
+// ColourMode decides how alive and dead cells are coloured.
+type ColourMode int
+
+const (
+	// Plain draws alive cells white and dead cells black.
+	Plain ColourMode = iota
+	// Age colours alive cells by how many generations they have been alive,
+	// from white for newborn cells through yellow and red to blue for long lived ones.
+	Age
+	// Trail draws alive cells white and leaves a fading red trail behind cells that have just died.
+	Trail
+	colourModes
+)
+
+func (m ColourMode) String() string {
+	switch m {
+	case Plain:
+		return "plain"
+	case Age:
+		return "age"
+	case Trail:
+		return "trail"
+	}
+	return "unknown"
+}
+
+// trailLength is how many generations a dead cell's trail takes to fade away.
+const trailLength = 24
+
+// maxAge is as far as cell ages are counted, which is well past the end of the age colours.
+const maxAge = 1 << 15
+
+type colour struct {
+	r, g, b uint8
+}
+
+// ageColours are the colours a cell goes through as it ages, at the age given.
+// Colours in between are blended.
+var ageColours = []struct {
+	age    int
+	colour colour
+}{
+	{1, colour{0xFF, 0xFF, 0xFF}},
+	{3, colour{0xFF, 0xE0, 0x40}},
+	{10, colour{0xFF, 0x80, 0x00}},
+	{30, colour{0xE0, 0x20, 0x20}},
+	{100, colour{0xA0, 0x20, 0xC0}},
+	{300, colour{0x30, 0x40, 0xFF}},
+}
+
+func blend(a, b colour, t float64) colour {
+	mix := func(x, y uint8) uint8 {
+		return uint8(float64(x) + (float64(y)-float64(x))*t)
+	}
+	return colour{mix(a.r, b.r), mix(a.g, b.g), mix(a.b, b.b)}
+}
+
+// ageColour gives the colour of a cell that has been alive for age generations.
+func ageColour(age int) colour {
+	if age <= ageColours[0].age {
+		return ageColours[0].colour
+	}
+	for i := 1; i < len(ageColours); i++ {
+		if age < ageColours[i].age {
+			from, to := ageColours[i-1], ageColours[i]
+			return blend(from.colour, to.colour, float64(age-from.age)/float64(to.age-from.age))
+		}
+	}
+	return ageColours[len(ageColours)-1].colour
+}
+
+// trailColour gives the colour of a cell that died age generations ago.
+func trailColour(age int) colour {
+	if age >= trailLength {
+		return colour{}
+	}
+	return blend(colour{0xC0, 0x30, 0x10}, colour{}, float64(age)/trailLength)
+}
+
+// cellColour gives the colour of a cell in the given mode.
+// age is how many generations the cell has been alive, or dead, for.
+func cellColour(mode ColourMode, alive bool, age int) colour {
+	switch {
+	case alive && mode == Age:
+		return ageColour(age)
+	case alive:
+		return colour{0xFF, 0xFF, 0xFF}
+	case mode == Trail:
+		return trailColour(age)
+	}
+	return colour{}
+}
@@ -0,0 +1,184 @@
+package sdl
+
+import (
+	"fmt"
+
+	"github.com/veandco/go-sdl2/sdl"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// editor turns the mouse and pattern actions into edits while execution is paused.
+// Dragging with the left button draws or erases cells, and with shift held selects cells to copy.
+// A pasted pattern follows the mouse until it is placed with a click.
+type editor struct {
+	w     *Window
+	edits chan<- gol.Edit
+	// pending edits are sent a few at a time without blocking, as the engine may resume at any time
+	pending []gol.Edit
+
+	// painting is set while the left button is held down to edit, paint is the state it gives cells
+	painting, paint bool
+	lastEdit        util.Cell
+	selecting       bool
+	selectFrom      util.Cell
+	placing         *util.Pattern
+}
+
+// mouse handles a mouse event, reporting whether it was used.
+func (ed *editor) mouse(event sdl.Event) bool {
+	switch e := event.(type) {
+	case *sdl.MouseButtonEvent:
+		if e.Button != sdl.BUTTON_LEFT {
+			return false
+		}
+		cellX, cellY, ok := ed.w.CellAt(e.X, e.Y)
+		cell := util.Cell{X: cellX, Y: cellY}
+		if e.Type == sdl.MOUSEBUTTONUP {
+			// a drag that started before pausing still belongs to the window
+			used := ed.painting || ed.selecting
+			ed.painting, ed.selecting = false, false
+			return used
+		}
+		switch {
+		case !ok:
+		case ed.placing != nil:
+			ed.place(*ed.placing, cell)
+			ed.placing = nil
+			ed.w.HidePattern()
+		case sdl.GetModState()&sdl.KMOD_SHIFT != 0:
+			ed.selecting = true
+			ed.selectFrom = cell
+			ed.w.SelectCells(cell, cell)
+		default:
+			// the first cell clicked decides whether the drag draws or erases
+			ed.painting = true
+			ed.paint = !ed.w.GetPixel(cellX, cellY)
+			ed.lastEdit = util.Cell{X: -1, Y: -1}
+			ed.edit(cell)
+		}
+		return true
+	case *sdl.MouseMotionEvent:
+		cellX, cellY, ok := ed.w.CellAt(e.X, e.Y)
+		cell := util.Cell{X: cellX, Y: cellY}
+		switch {
+		case !ok:
+			return ed.painting || ed.selecting
+		case ed.placing != nil:
+			ed.w.ShowPattern(*ed.placing, cell)
+		case ed.selecting:
+			ed.w.SelectCells(ed.selectFrom, cell)
+		case ed.painting:
+			ed.edit(cell)
+		default:
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+func (ed *editor) edit(cell util.Cell) {
+	if cell == ed.lastEdit {
+		return
+	}
+	ed.lastEdit = cell
+	ed.pending = append(ed.pending, gol.Edit{Cell: cell, Alive: ed.paint})
+}
+
+// place edits the board to match a pattern with its top left corner at the given cell,
+// wrapping around the edges. Only the cells that change are edited.
+func (ed *editor) place(pattern util.Pattern, at util.Cell) {
+	alive := make(map[util.Cell]bool)
+	for _, cell := range pattern.Alive {
+		alive[cell] = true
+	}
+	for y := 0; y < pattern.Height; y++ {
+		for x := 0; x < pattern.Width; x++ {
+			cell := util.Cell{X: (at.X + x) % int(ed.w.Width), Y: (at.Y + y) % int(ed.w.Height)}
+			state := alive[util.Cell{X: x, Y: y}]
+			if ed.w.GetPixel(cell.X, cell.Y) != state {
+				ed.pending = append(ed.pending, gol.Edit{Cell: cell, Alive: state})
+			}
+		}
+	}
+}
+
+// action does the pattern actions.
+func (ed *editor) action(action gol.Action) {
+	switch action {
+	case gol.Copy:
+		pattern, ok := ed.w.Selected()
+		if !ok {
+			fmt.Println("Select cells to copy by dragging with shift held")
+			return
+		}
+		err := sdl.SetClipboardText(pattern.RLE())
+		if err != nil {
+			fmt.Println("Copy failed:", err)
+			return
+		}
+		fmt.Printf("Copied %dx%d pattern\n", pattern.Width, pattern.Height)
+	case gol.Paste:
+		text, err := sdl.GetClipboardText()
+		if err != nil {
+			fmt.Println("Paste failed:", err)
+			return
+		}
+		pattern, err := util.ParseRLE(text, int(ed.w.Width), int(ed.w.Height))
+		if err != nil {
+			fmt.Println("Paste failed:", err)
+			return
+		}
+		ed.placing = &pattern
+		ed.showPlacing()
+	case gol.Rotate, gol.Mirror:
+		if ed.placing == nil {
+			return
+		}
+		var pattern util.Pattern
+		if action == gol.Mirror {
+			pattern = ed.placing.Mirror()
+		} else {
+			pattern = ed.placing.Rotate()
+		}
+		ed.placing = &pattern
+		ed.showPlacing()
+	case gol.Cancel:
+		ed.reset()
+	}
+}
+
+// showPlacing previews the pattern being placed under the mouse.
+func (ed *editor) showPlacing() {
+	x, y, _ := sdl.GetMouseState()
+	cellX, cellY, ok := ed.w.CellAt(x, y)
+	if !ok {
+		cellX, cellY = 0, 0
+	}
+	ed.w.ShowPattern(*ed.placing, util.Cell{X: cellX, Y: cellY})
+}
+
+// send passes on as many pending edits as the engine will take right now.
+func (ed *editor) send() {
+	for len(ed.pending) > 0 {
+		select {
+		case ed.edits <- ed.pending[0]:
+			ed.pending = ed.pending[1:]
+		default:
+			return
+		}
+	}
+}
+
+// reset drops everything in progress, for when execution resumes.
+func (ed *editor) reset() {
+	if ed.placing == nil && ed.w.selection == nil && len(ed.pending) == 0 && !ed.painting && !ed.selecting {
+		return
+	}
+	ed.pending = nil
+	ed.painting, ed.selecting = false, false
+	ed.placing = nil
+	ed.w.preview = nil
+	ed.w.ClearSelection()
+}
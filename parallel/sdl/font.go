@@ -0,0 +1,152 @@
+package sdl
+
+import (
+	"strings"
+
+	"github.com/veandco/go-sdl2/sdl"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// glyphs is a 5x7 bitmap font. Letters are drawn in upper case and anything missing is drawn as a box.
+var glyphs = map[rune][7]string{
+	'A':  {" ### ", "#   #", "#   #", "#####", "#   #", "#   #", "#   #"},
+	'B':  {"#### ", "#   #", "#   #", "#### ", "#   #", "#   #", "#### "},
+	'C':  {" ### ", "#   #", "#    ", "#    ", "#    ", "#   #", " ### "},
+	'D':  {"#### ", "#   #", "#   #", "#   #", "#   #", "#   #", "#### "},
+	'E':  {"#####", "#    ", "#    ", "#### ", "#    ", "#    ", "#####"},
+	'F':  {"#####", "#    ", "#    ", "#### ", "#    ", "#    ", "#    "},
+	'G':  {" ### ", "#   #", "#    ", "# ###", "#   #", "#   #", " ####"},
+	'H':  {"#   #", "#   #", "#   #", "#####", "#   #", "#   #", "#   #"},
+	'I':  {" ### ", "  #  ", "  #  ", "  #  ", "  #  ", "  #  ", " ### "},
+	'J':  {"  ###", "   # ", "   # ", "   # ", "   # ", "#  # ", " ##  "},
+	'K':  {"#   #", "#  # ", "# #  ", "##   ", "# #  ", "#  # ", "#   #"},
+	'L':  {"#    ", "#    ", "#    ", "#    ", "#    ", "#    ", "#####"},
+	'M':  {"#   #", "## ##", "# # #", "# # #", "#   #", "#   #", "#   #"},
+	'N':  {"#   #", "#   #", "##  #", "# # #", "#  ##", "#   #", "#   #"},
+	'O':  {" ### ", "#   #", "#   #", "#   #", "#   #", "#   #", " ### "},
+	'P':  {"#### ", "#   #", "#   #", "#### ", "#    ", "#    ", "#    "},
+	'Q':  {" ### ", "#   #", "#   #", "#   #", "# # #", "#  # ", " ## #"},
+	'R':  {"#### ", "#   #", "#   #", "#### ", "# #  ", "#  # ", "#   #"},
+	'S':  {" ####", "#    ", "#    ", " ### ", "    #", "    #", "#### "},
+	'T':  {"#####", "  #  ", "  #  ", "  #  ", "  #  ", "  #  ", "  #  "},
+	'U':  {"#   #", "#   #", "#   #", "#   #", "#   #", "#   #", " ### "},
+	'V':  {"#   #", "#   #", "#   #", "#   #", "#   #", " # # ", "  #  "},
+	'W':  {"#   #", "#   #", "#   #", "# # #", "# # #", "# # #", " # # "},
+	'X':  {"#   #", "#   #", " # # ", "  #  ", " # # ", "#   #", "#   #"},
+	'Y':  {"#   #", "#   #", " # # ", "  #  ", "  #  ", "  #  ", "  #  "},
+	'Z':  {"#####", "    #", "   # ", "  #  ", " #   ", "#    ", "#####"},
+	'0':  {" ### ", "#   #", "#  ##", "# # #", "##  #", "#   #", " ### "},
+	'1':  {"  #  ", " ##  ", "  #  ", "  #  ", "  #  ", "  #  ", " ### "},
+	'2':  {" ### ", "#   #", "    #", "   # ", "  #  ", " #   ", "#####"},
+	'3':  {"#####", "   # ", "  #  ", "   # ", "    #", "#   #", " ### "},
+	'4':  {"   # ", "  ## ", " # # ", "#  # ", "#####", "   # ", "   # "},
+	'5':  {"#####", "#    ", "#### ", "    #", "    #", "#   #", " ### "},
+	'6':  {"  ## ", " #   ", "#    ", "#### ", "#   #", "#   #", " ### "},
+	'7':  {"#####", "    #", "   # ", "  #  ", " #   ", " #   ", " #   "},
+	'8':  {" ### ", "#   #", "#   #", " ### ", "#   #", "#   #", " ### "},
+	'9':  {" ### ", "#   #", "#   #", " ####", "    #", "   # ", " ##  "},
+	' ':  {"     ", "     ", "     ", "     ", "     ", "     ", "     "},
+	'+':  {"     ", "  #  ", "  #  ", "#####", "  #  ", "  #  ", "     "},
+	'-':  {"     ", "     ", "     ", "#####", "     ", "     ", "     "},
+	'=':  {"     ", "     ", "#####", "     ", "#####", "     ", "     "},
+	'.':  {"     ", "     ", "     ", "     ", "     ", " ##  ", " ##  "},
+	',':  {"     ", "     ", "     ", "     ", " ##  ", "  #  ", " #   "},
+	':':  {"     ", " ##  ", " ##  ", "     ", " ##  ", " ##  ", "     "},
+	'/':  {"     ", "    #", "   # ", "  #  ", " #   ", "#    ", "     "},
+	'(':  {"   # ", "  #  ", " #   ", " #   ", " #   ", "  #  ", "   # "},
+	')':  {" #   ", "  #  ", "   # ", "   # ", "   # ", "  #  ", " #   "},
+	'[':  {" ### ", " #   ", " #   ", " #   ", " #   ", " #   ", " ### "},
+	']':  {" ### ", "   # ", "   # ", "   # ", "   # ", "   # ", " ### "},
+	'\'': {"  #  ", "  #  ", " #   ", "     ", "     ", "     ", "     "},
+	'*':  {"     ", "  #  ", "# # #", " ### ", "# # #", "  #  ", "     "},
+	'#':  {" # # ", " # # ", "#####", " # # ", "#####", " # # ", " # # "},
+	'<':  {"   # ", "  #  ", " #   ", "#    ", " #   ", "  #  ", "   # "},
+	'>':  {" #   ", "  #  ", "   # ", "    #", "   # ", "  #  ", " #   "},
+	'?':  {" ### ", "#   #", "    #", "   # ", "  #  ", "     ", "  #  "},
+	'_':  {"     ", "     ", "     ", "     ", "     ", "     ", "#####"},
+}
+
+// unknownGlyph is drawn for any character the font doesn't have.
+var unknownGlyph = [7]string{"#####", "#   #", "#   #", "#   #", "#   #", "#   #", "#####"}
+
+const (
+	glyphW = 5
+	glyphH = 7
+	// fontScale is how many screen pixels each pixel of a glyph takes
+	fontScale = 2
+	// lineH and charW include the gap between lines and characters
+	lineH = (glyphH + 2) * fontScale
+	charW = (glyphW + 1) * fontScale
+)
+
+// drawText draws text in the current draw colour with its top left corner at (x, y).
+func (w *Window) drawText(text string, x, y int32) {
+	var rects []sdl.Rect
+	for i, char := range []rune(strings.ToUpper(text)) {
+		glyph, ok := glyphs[char]
+		if !ok {
+			glyph = unknownGlyph
+		}
+		for row, line := range glyph {
+			for col, pixel := range line {
+				if pixel == '#' {
+					rects = append(rects, sdl.Rect{
+						X: x + int32(i*charW+col*fontScale),
+						Y: y + int32(row*fontScale),
+						W: fontScale,
+						H: fontScale,
+					})
+				}
+			}
+		}
+	}
+	if len(rects) > 0 {
+		err := w.renderer.FillRects(rects)
+		util.Check(err)
+	}
+}
+
+// helpMargin is the space around the help overlay and the text inside it.
+const helpMargin = 12
+
+// SetHelp gives the lines shown by the help overlay.
+func (w *Window) SetHelp(lines []string) {
+	w.help = lines
+}
+
+// ToggleHelp shows or hides the help overlay.
+func (w *Window) ToggleHelp() {
+	w.showHelp = !w.showHelp
+	w.present()
+}
+
+// drawHelp lists the help lines in a box over the top left of the window.
+func (w *Window) drawHelp() {
+	widest := 0
+	for _, line := range w.help {
+		if len(line) > widest {
+			widest = len(line)
+		}
+	}
+	box := sdl.Rect{
+		X: helpMargin,
+		Y: helpMargin,
+		W: int32(widest*charW + 2*helpMargin),
+		H: int32(len(w.help)*lineH + 2*helpMargin),
+	}
+
+	err := w.renderer.SetDrawBlendMode(sdl.BLENDMODE_BLEND)
+	util.Check(err)
+	err = w.renderer.SetDrawColor(0x10, 0x10, 0x10, 0xD0)
+	util.Check(err)
+	err = w.renderer.FillRect(&box)
+	util.Check(err)
+	err = w.renderer.SetDrawBlendMode(sdl.BLENDMODE_NONE)
+	util.Check(err)
+
+	err = w.renderer.SetDrawColor(0xE0, 0xE0, 0xE0, 0xFF)
+	util.Check(err)
+	for i, line := range w.help {
+		w.drawText(line, box.X+helpMargin, box.Y+helpMargin+int32(i*lineH))
+	}
+}
@@ -0,0 +1,101 @@
+package sdl
+
+import (
+	"github.com/veandco/go-sdl2/sdl"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// graphHeight is the height of the population graph below the board.
+const graphHeight = 120
+
+// graphMargin keeps the plot off the edges of the graph.
+const graphMargin = 6
+
+// ShowPopulation plots a population history below the board. The loop keeps the history up to date.
+func (w *Window) ShowPopulation(history *gol.PopulationHistory) {
+	w.history = history
+	w.present()
+}
+
+// ToggleGraph shows or hides the population graph.
+func (w *Window) ToggleGraph() {
+	w.graph = !w.graph
+	w.present()
+}
+
+func (w *Window) graphShown() bool {
+	return w.graph && w.history != nil
+}
+
+// column is the range of the plot that falls in one column of pixels.
+type column struct {
+	seen                   bool
+	first, last, low, high int32
+}
+
+// drawGraph plots the population over time in area, scaling both axes to fit the whole run.
+// Where many turns fall in one column of pixels, the column is filled between the lowest and highest population.
+func (w *Window) drawGraph(area sdl.Rect) {
+	err := w.renderer.SetDrawColor(0x18, 0x18, 0x18, 0xFF)
+	util.Check(err)
+	err = w.renderer.FillRect(&area)
+	util.Check(err)
+	err = w.renderer.SetDrawColor(0x50, 0x50, 0x50, 0xFF)
+	util.Check(err)
+	err = w.renderer.DrawLine(area.X, area.Y, area.X+area.W, area.Y)
+	util.Check(err)
+
+	points := w.history.Points
+	plotW, plotH := area.W-2*graphMargin, area.H-2*graphMargin
+	if len(points) < 2 || plotW < 2 || plotH < 2 {
+		return
+	}
+	first, last := points[0].Turn, points[len(points)-1].Turn
+	most := 1
+	for _, point := range points {
+		if point.Alive > most {
+			most = point.Alive
+		}
+	}
+
+	// faint lines at half and all of the highest population
+	err = w.renderer.SetDrawColor(0x30, 0x30, 0x30, 0xFF)
+	util.Check(err)
+	for _, y := range []int32{area.Y + graphMargin, area.Y + graphMargin + plotH/2} {
+		err = w.renderer.DrawLine(area.X+graphMargin, y, area.X+graphMargin+plotW, y)
+		util.Check(err)
+	}
+
+	columns := make([]column, plotW)
+	span := last - first
+	for _, point := range points {
+		x := int32(int64(point.Turn-first) * int64(plotW-1) / int64(span))
+		y := area.Y + graphMargin + plotH - 1 - int32(int64(point.Alive)*int64(plotH-1)/int64(most))
+		c := &columns[x]
+		if !c.seen {
+			*c = column{seen: true, first: y, last: y, low: y, high: y}
+			continue
+		}
+		c.last = y
+		c.low = maxInt32(c.low, y)
+		c.high = minInt32(c.high, y)
+	}
+
+	err = w.renderer.SetDrawColor(0x40, 0xC0, 0x60, 0xFF)
+	util.Check(err)
+	previous := -1
+	for x, c := range columns {
+		if !c.seen {
+			continue
+		}
+		screenX := area.X + graphMargin + int32(x)
+		if previous >= 0 {
+			err = w.renderer.DrawLine(area.X+graphMargin+int32(previous), columns[previous].last, screenX, c.first)
+			util.Check(err)
+		}
+		err = w.renderer.DrawLine(screenX, c.high, screenX, c.low)
+		util.Check(err)
+		previous = x
+	}
+}
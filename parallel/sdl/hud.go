@@ -0,0 +1,60 @@
+package sdl
+
+import (
+	"fmt"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// hudInterval is how often the title bar is updated at most, as setting it is slow on some window managers.
+const hudInterval = 100 * time.Millisecond
+
+// hud keeps the details of the run shown in the window's title bar.
+type hud struct {
+	turns          int
+	turnsPerSecond float64
+	state          gol.State
+	shown          time.Time
+	changed        bool
+	// alive is from the latest AliveCellsCount, or -1 before the first one arrives
+	alive int
+}
+
+func newHud() *hud {
+	return &hud{alive: -1, state: gol.Executing, changed: true}
+}
+
+// update takes in any details an event has for the hud.
+func (h *hud) update(event gol.Event) {
+	switch e := event.(type) {
+	case gol.TurnComplete:
+		h.turns = e.CompletedTurns
+	case gol.StateChange:
+		h.turns = e.CompletedTurns
+		h.state = e.NewState
+	case gol.AliveCellsCount:
+		h.alive = e.CellsCount
+	case gol.Metrics:
+		h.turnsPerSecond = e.TurnsPerSecond
+	default:
+		return
+	}
+	h.changed = true
+}
+
+// show updates the window title, unless it was updated very recently.
+// Changes of state are always shown straight away.
+func (h *hud) show(w *Window, force bool) {
+	if !h.changed || (!force && time.Since(h.shown) < hudInterval) {
+		return
+	}
+	alive := "-"
+	if h.alive >= 0 {
+		alive = fmt.Sprint(h.alive)
+	}
+	w.SetTitle(fmt.Sprintf("GOL GUI | Turn %d | Alive %v | %.1f turns/s | %v | %v",
+		h.turns, alive, h.turnsPerSecond, h.state, gol.Rule))
+	h.shown = time.Now()
+	h.changed = false
+}
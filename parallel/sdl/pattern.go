@@ -0,0 +1,116 @@
+package sdl
+
+import (
+	"github.com/veandco/go-sdl2/sdl"
+	"uk.ac.bris.cs/gameoflife/util"
+)
+
+// SelectCells outlines the rectangle of cells between two corners, ready to be copied.
+func (w *Window) SelectCells(from, to util.Cell) {
+	w.selection = &[2]util.Cell{from, to}
+	w.present()
+}
+
+// ClearSelection removes the outline from SelectCells.
+func (w *Window) ClearSelection() {
+	w.selection = nil
+	w.present()
+}
+
+// selected gives the top left corner and size of the selection.
+func (w *Window) selected() (int, int, int, int) {
+	from, to := w.selection[0], w.selection[1]
+	x, y := minInt(from.X, to.X), minInt(from.Y, to.Y)
+	return x, y, maxInt(from.X, to.X) - x + 1, maxInt(from.Y, to.Y) - y + 1
+}
+
+// Selected gives the alive cells in the selection as a pattern, and whether anything is selected.
+func (w *Window) Selected() (util.Pattern, bool) {
+	if w.selection == nil {
+		return util.Pattern{}, false
+	}
+	x, y, width, height := w.selected()
+	pattern := util.Pattern{Width: width, Height: height}
+	for cellY := y; cellY < y+height; cellY++ {
+		for cellX := x; cellX < x+width; cellX++ {
+			if w.GetPixel(cellX, cellY) {
+				pattern.Alive = append(pattern.Alive, util.Cell{X: cellX - x, Y: cellY - y})
+			}
+		}
+	}
+	return pattern, true
+}
+
+// ShowPattern previews a pattern with its top left corner at the given cell, wrapping around the edges of the board.
+func (w *Window) ShowPattern(pattern util.Pattern, at util.Cell) {
+	w.preview = &pattern
+	w.previewAt = at
+	w.present()
+}
+
+// HidePattern removes the preview from ShowPattern.
+func (w *Window) HidePattern() {
+	w.preview = nil
+	w.present()
+}
+
+// cellRect gives where a rectangle of cells is on screen.
+func (w *Window) cellRect(x, y, width, height int) sdl.Rect {
+	scale := zoomLevels[w.zoom]
+	return sdl.Rect{
+		X: w.offsetX + int32(float64(x)*scale),
+		Y: w.offsetY + int32(float64(y)*scale),
+		W: maxInt32(1, int32(float64(width)*scale)),
+		H: maxInt32(1, int32(float64(height)*scale)),
+	}
+}
+
+// drawPatterns draws the selection outline and the pattern preview.
+func (w *Window) drawPatterns() {
+	if w.selection != nil {
+		x, y, width, height := w.selected()
+		rect := w.cellRect(x, y, width, height)
+		err := w.renderer.SetDrawColor(0x40, 0xA0, 0xFF, 0xFF)
+		util.Check(err)
+		err = w.renderer.DrawRect(&rect)
+		util.Check(err)
+	}
+
+	if w.preview != nil {
+		var rects []sdl.Rect
+		for _, cell := range w.preview.Alive {
+			x := (w.previewAt.X + cell.X) % int(w.Width)
+			y := (w.previewAt.Y + cell.Y) % int(w.Height)
+			rects = append(rects, w.cellRect(x, y, 1, 1))
+		}
+		err := w.renderer.SetDrawBlendMode(sdl.BLENDMODE_BLEND)
+		util.Check(err)
+		err = w.renderer.SetDrawColor(0x40, 0xFF, 0x60, 0xA0)
+		util.Check(err)
+		if len(rects) > 0 {
+			err = w.renderer.FillRects(rects)
+			util.Check(err)
+		}
+		err = w.renderer.SetDrawBlendMode(sdl.BLENDMODE_NONE)
+		util.Check(err)
+		outline := w.cellRect(w.previewAt.X, w.previewAt.Y, w.preview.Width, w.preview.Height)
+		err = w.renderer.SetDrawColor(0x40, 0xFF, 0x60, 0xFF)
+		util.Check(err)
+		err = w.renderer.DrawRect(&outline)
+		util.Check(err)
+	}
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
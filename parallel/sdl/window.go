@@ -4,43 +4,126 @@ import (
 	"fmt"
 
 	"github.com/veandco/go-sdl2/sdl"
+	"uk.ac.bris.cs/gameoflife/gol"
+	"uk.ac.bris.cs/gameoflife/render"
 	"uk.ac.bris.cs/gameoflife/util"
 )
 
+// zoomLevels are the sizes a cell can be drawn at, in screen pixels.
+// Zoomed in, cells are always a whole number of pixels wide so the board stays sharp.
+var zoomLevels = []float64{1.0 / 16, 1.0 / 8, 1.0 / 4, 1.0 / 2, 1, 2, 3, 4, 6, 8, 12, 16, 24, 32, 48, 64}
+
+// gridFrom is the smallest zoom level at which the grid overlay is drawn.
+const gridFrom = 6
+
+// Window has to stay usable as a render.Renderer
+var _ render.Renderer = (*Window)(nil)
+
 type Window struct {
 	Width, Height int32
 	window        *sdl.Window
 	renderer      *sdl.Renderer
 	texture       *sdl.Texture
 	pixels        []byte
+
+	// alive and flipped are the state of every cell, flipped holding the generation it last flipped at.
+	// turn counts the generations, so a cell's age is worked out only when it is painted
+	alive   []bool
+	flipped []int32
+	turn    int32
+	colours ColourMode
+	// population is the number of alive cells, kept up to date as they flip
+	population int
+
+	// zoom indexes zoomLevels, offsetX and offsetY are where the board's top left corner is drawn
+	zoom             int
+	offsetX, offsetY int32
+	// fit keeps the whole board fitted to the window until the user zooms or pans
+	fit      bool
+	grid     bool
+	dragging bool
+
+	// history is plotted below the board while graph is set
+	history *gol.PopulationHistory
+	graph   bool
+
+	// help is listed over the board while showHelp is set
+	help     []string
+	showHelp bool
+
+	// selection holds two opposite corners of the selected cells
+	selection *[2]util.Cell
+	preview   *util.Pattern
+	previewAt util.Cell
 }
 
 func filterEvent(e sdl.Event, userdata interface{}) bool {
-	return e.GetType() == sdl.KEYDOWN || e.GetType() == sdl.QUIT
+	switch e.GetType() {
+	case sdl.KEYDOWN, sdl.QUIT, sdl.WINDOWEVENT,
+		sdl.MOUSEWHEEL, sdl.MOUSEBUTTONDOWN, sdl.MOUSEBUTTONUP, sdl.MOUSEMOTION:
+		return true
+	}
+	return false
+}
+
+// fitZoom gives the largest zoom level at which a width x height board fits in the given space.
+func fitZoom(width, height, spaceW, spaceH int32) int {
+	for zoom := len(zoomLevels) - 1; zoom > 0; zoom-- {
+		if float64(width)*zoomLevels[zoom] <= float64(spaceW) && float64(height)*zoomLevels[zoom] <= float64(spaceH) {
+			return zoom
+		}
+	}
+	return 0
 }
 
 func NewWindow(width, height int32) *Window {
 	err := sdl.Init(sdl.INIT_EVERYTHING)
 	util.Check(err)
-	window, err := sdl.CreateWindow("GOL GUI", sdl.WINDOWPOS_CENTERED, sdl.WINDOWPOS_CENTERED, width, height, sdl.WINDOW_SHOWN)
+
+	// Open the window as big as comfortably fits on the screen, so small boards aren't a postage stamp
+	windowW, windowH := width, height
+	bounds, err := sdl.GetDisplayUsableBounds(0)
+	if err == nil {
+		zoom := fitZoom(width, height, bounds.W*4/5, bounds.H*4/5-graphHeight)
+		windowW = int32(float64(width) * zoomLevels[zoom])
+		windowH = int32(float64(height) * zoomLevels[zoom])
+	}
+	windowH += graphHeight
+
+	window, err := sdl.CreateWindow("GOL GUI", sdl.WINDOWPOS_CENTERED, sdl.WINDOWPOS_CENTERED, windowW, windowH, sdl.WINDOW_SHOWN|sdl.WINDOW_RESIZABLE)
 	util.Check(err)
 	renderer, err := sdl.CreateRenderer(window, -1, sdl.WINDOW_SHOWN)
 	util.Check(err)
-	sdl.SetHint(sdl.HINT_RENDER_SCALE_QUALITY, "linear")
-	err = renderer.SetLogicalSize(width, height)
-	util.Check(err)
+	// Nearest neighbour scaling keeps cells as crisp squares when zoomed in
+	sdl.SetHint(sdl.HINT_RENDER_SCALE_QUALITY, "nearest")
 	texture, err := renderer.CreateTexture(sdl.PIXELFORMAT_ARGB8888, sdl.TEXTUREACCESS_STATIC, width, height)
 	util.Check(err)
+	// Dead cells have a clear alpha, which must not let the background through
+	err = texture.SetBlendMode(sdl.BLENDMODE_NONE)
+	util.Check(err)
 
 	sdl.SetEventFilterFunc(filterEvent, nil)
 	return &Window{
-		width,
-		height,
-		window,
-		renderer,
-		texture,
-		make([]byte, width*height*4),
+		Width:    width,
+		Height:   height,
+		window:   window,
+		renderer: renderer,
+		texture:  texture,
+		pixels:   make([]byte, width*height*4),
+		alive:    make([]bool, width*height),
+		flipped:  newFlipped(width * height),
+		fit:      true,
+		graph:    true,
+	}
+}
+
+// newFlipped gives the flips of cells that have been dead for as long as can be remembered.
+func newFlipped(cells int32) []int32 {
+	flipped := make([]int32, cells)
+	for i := range flipped {
+		flipped[i] = -maxAge
 	}
+	return flipped
 }
 
 func (w *Window) Destroy() {
@@ -54,15 +137,158 @@ func (w *Window) Destroy() {
 }
 
 func (w *Window) RenderFrame() {
+	// plain pixels are kept up to date by FlipPixel, any other colours depend on the cells' ages
+	if w.colours != Plain {
+		w.paint()
+	}
 	err := w.texture.Update(nil, w.pixels, int(w.Width*4))
 	util.Check(err)
+	w.present()
+}
+
+// present redraws the last rendered frame with the current zoom and pan.
+func (w *Window) present() {
+	windowW, windowH, err := w.renderer.GetOutputSize()
+	util.Check(err)
+	// the graph takes the bottom of the window, leaving the rest for the board
+	if w.graphShown() {
+		windowH -= graphHeight
+	}
+	if w.fit {
+		w.zoom = fitZoom(w.Width, w.Height, windowW, windowH)
+		scale := zoomLevels[w.zoom]
+		w.offsetX = (windowW - int32(float64(w.Width)*scale)) / 2
+		w.offsetY = (windowH - int32(float64(w.Height)*scale)) / 2
+	}
+	scale := zoomLevels[w.zoom]
+	board := sdl.Rect{X: w.offsetX, Y: w.offsetY, W: int32(float64(w.Width) * scale), H: int32(float64(w.Height) * scale)}
+
+	// Grey around the board so its edges can be seen against dead cells
+	err = w.renderer.SetDrawColor(0x30, 0x30, 0x30, 0xFF)
+	util.Check(err)
 	err = w.renderer.Clear()
 	util.Check(err)
-	err = w.renderer.Copy(w.texture, nil, nil)
+	err = w.renderer.Copy(w.texture, nil, &board)
 	util.Check(err)
+
+	if w.grid && scale >= gridFrom {
+		err = w.renderer.SetDrawColor(0x40, 0x40, 0x40, 0xFF)
+		util.Check(err)
+		// Only the lines that are actually on screen are drawn
+		first := maxInt32(0, -w.offsetX/int32(scale))
+		last := minInt32(w.Width, (windowW-w.offsetX)/int32(scale)+1)
+		for x := first; x <= last; x++ {
+			lineX := w.offsetX + x*int32(scale)
+			err = w.renderer.DrawLine(lineX, maxInt32(board.Y, 0), lineX, minInt32(board.Y+board.H, windowH))
+			util.Check(err)
+		}
+		first = maxInt32(0, -w.offsetY/int32(scale))
+		last = minInt32(w.Height, (windowH-w.offsetY)/int32(scale)+1)
+		for y := first; y <= last; y++ {
+			lineY := w.offsetY + y*int32(scale)
+			err = w.renderer.DrawLine(maxInt32(board.X, 0), lineY, minInt32(board.X+board.W, windowW), lineY)
+			util.Check(err)
+		}
+	}
+	w.drawPatterns()
+	if w.graphShown() {
+		w.drawGraph(sdl.Rect{X: 0, Y: windowH, W: windowW, H: graphHeight})
+	}
+	if w.showHelp {
+		w.drawHelp()
+	}
 	w.renderer.Present()
 }
 
+// HandleEvent zooms and pans the view for mouse and window events. It reports whether the event was used.
+// The scroll wheel zooms around the mouse pointer and dragging with any button pans.
+func (w *Window) HandleEvent(event sdl.Event) bool {
+	switch e := event.(type) {
+	case *sdl.MouseWheelEvent:
+		if e.Y == 0 {
+			return false
+		}
+		mouseX, mouseY, _ := sdl.GetMouseState()
+		zoom := w.zoom + 1
+		if e.Y < 0 {
+			zoom = w.zoom - 1
+		}
+		w.zoomAround(zoom, mouseX, mouseY)
+	case *sdl.MouseButtonEvent:
+		w.dragging = e.Type == sdl.MOUSEBUTTONDOWN
+		return true
+	case *sdl.MouseMotionEvent:
+		if !w.dragging {
+			return false
+		}
+		w.fit = false
+		w.offsetX += e.XRel
+		w.offsetY += e.YRel
+	case *sdl.WindowEvent:
+		if e.Event != sdl.WINDOWEVENT_SIZE_CHANGED && e.Event != sdl.WINDOWEVENT_EXPOSED {
+			return false
+		}
+	default:
+		return false
+	}
+	w.present()
+	return true
+}
+
+// zoomAround changes the zoom level, keeping the cell under (x, y) on the screen where it was.
+func (w *Window) zoomAround(zoom int, x, y int32) {
+	if zoom < 0 || zoom >= len(zoomLevels) {
+		return
+	}
+	old := zoomLevels[w.zoom]
+	scale := zoomLevels[zoom]
+	w.offsetX = x - int32(float64(x-w.offsetX)/old*scale)
+	w.offsetY = y - int32(float64(y-w.offsetY)/old*scale)
+	w.zoom = zoom
+	w.fit = false
+}
+
+// FitToWindow goes back to showing the whole board, as big as it fits in the window.
+func (w *Window) FitToWindow() {
+	w.fit = true
+	w.present()
+}
+
+// ToggleGrid turns the grid between cells on and off. It is only drawn when zoomed in far enough to see it.
+func (w *Window) ToggleGrid() {
+	w.grid = !w.grid
+	w.present()
+}
+
+// CellAt gives the cell under a point in the window, and whether there is one there at all.
+func (w *Window) CellAt(x, y int32) (int, int, bool) {
+	scale := zoomLevels[w.zoom]
+	cellX := int(float64(x-w.offsetX) / scale)
+	cellY := int(float64(y-w.offsetY) / scale)
+	if x < w.offsetX || y < w.offsetY || cellX >= int(w.Width) || cellY >= int(w.Height) {
+		return 0, 0, false
+	}
+	return cellX, cellY, true
+}
+
+func minInt32(a, b int32) int32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt32(a, b int32) int32 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func (w *Window) SetTitle(title string) {
+	w.window.SetTitle(title)
+}
+
 func (w *Window) PollEvent() sdl.Event {
 	return sdl.PollEvent()
 }
@@ -73,6 +299,16 @@ func (w *Window) SetPixel(x, y int) {
 	w.pixels[4*(y*width+x)+1] = 0xFF
 	w.pixels[4*(y*width+x)+2] = 0xFF
 	w.pixels[4*(y*width+x)+3] = 0xFF
+	if !w.alive[y*width+x] {
+		w.alive[y*width+x] = true
+		w.flipped[y*width+x] = w.turn
+		w.population++
+	}
+}
+
+// GetPixel reports whether the cell at (x, y) is currently drawn as alive.
+func (w *Window) GetPixel(x, y int) bool {
+	return w.alive[y*int(w.Width)+x]
 }
 
 func (w *Window) FlipPixel(x, y int) {
@@ -85,20 +321,81 @@ func (w *Window) FlipPixel(x, y int) {
 	w.pixels[4*(y*width+x)+1] = ^w.pixels[4*(y*width+x)+1]
 	w.pixels[4*(y*width+x)+2] = ^w.pixels[4*(y*width+x)+2]
 	w.pixels[4*(y*width+x)+3] = ^w.pixels[4*(y*width+x)+3]
+	w.alive[y*width+x] = !w.alive[y*width+x]
+	w.flipped[y*width+x] = w.turn
+	if w.alive[y*width+x] {
+		w.population++
+	} else {
+		w.population--
+	}
 }
 
-func (w *Window) CountPixels() int {
-	count := 0
-	for i := 0; i < int(w.Width) * int(w.Height) * 4; i += 4 {
-		if w.pixels[i] == 0xFF {
-			count++
+// rebaseAt is the generation at which the flips are counted from zero again, long before turn could overflow.
+const rebaseAt = 1 << 30
+
+// AgeCells adds the given number of generations to the age of every cell.
+// It should be called once per completed turn, before RenderFrame.
+func (w *Window) AgeCells(generations int) {
+	if int(w.turn)+generations < rebaseAt {
+		w.turn += int32(generations)
+		return
+	}
+	// once in a billion generations every flip is moved back, so that only ages past maxAge are lost
+	turn := int(w.turn) + generations
+	for i, flipped := range w.flipped {
+		if turn-int(flipped) < maxAge {
+			w.flipped[i] = int32(int(flipped) - turn)
+		} else {
+			w.flipped[i] = -maxAge
+		}
+	}
+	w.turn = 0
+}
+
+// age gives how many generations the cell at i has been alive, or dead, for.
+func (w *Window) age(i int) int {
+	age := int(w.turn - w.flipped[i])
+	if age > maxAge {
+		return maxAge
+	}
+	return age
+}
+
+// CycleColours switches to the next colour mode and redraws the board with it.
+func (w *Window) CycleColours() ColourMode {
+	w.colours = (w.colours + 1) % colourModes
+	w.paint()
+	w.RenderFrame()
+	return w.colours
+}
+
+// paint recolours every pixel from the cells' state in the current colour mode.
+func (w *Window) paint() {
+	for i, alive := range w.alive {
+		c := cellColour(w.colours, alive, w.age(i))
+		// FlipPixel inverts every byte, so in plain colours a dead cell has to be all zeros
+		alpha := uint8(0xFF)
+		if !alive && w.colours == Plain {
+			alpha = 0
 		}
+		w.pixels[4*i+0] = c.b
+		w.pixels[4*i+1] = c.g
+		w.pixels[4*i+2] = c.r
+		w.pixels[4*i+3] = alpha
 	}
-	return count
+}
+
+func (w *Window) CountPixels() int {
+	return w.population
 }
 
 func (w *Window) ClearPixels() {
 	for i := range w.pixels {
 		w.pixels[i] = 0
 	}
+	for i := range w.alive {
+		w.alive[i] = false
+		w.flipped[i] = w.turn - maxAge
+	}
+	w.population = 0
 }
@@ -0,0 +1,242 @@
+// Package term shows the board in a terminal, for when there is no display to open an SDL window on.
+package term
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"time"
+
+	"uk.ac.bris.cs/gameoflife/gol"
+)
+
+// frameInterval is how often a frame is drawn at most, as terminals are slow to redraw.
+const frameInterval = 50 * time.Millisecond
+
+// ANSI escape sequences
+const (
+	hideCursor  = "\x1b[?25l"
+	showCursor  = "\x1b[?25h"
+	clearScreen = "\x1b[2J"
+	home        = "\x1b[H"
+	clearLine   = "\x1b[K"
+)
+
+// minRows and minCols are the smallest terminal drawn in, whatever size it claims to be.
+// The status takes two rows, so at least one is left for the board.
+const (
+	minRows = 3
+	minCols = 1
+)
+
+// half blocks let each character show two rows of cells, indexed by top + 2*bottom
+var blocks = []string{" ", "▀", "▄", "█"}
+
+type terminal struct {
+	width, height int
+	alive         []bool
+	population    int
+	out           *bufio.Writer
+
+	rows, cols int
+	sized      time.Time
+	drawn      time.Time
+
+	turns  int
+	state  gol.State
+	status string
+}
+
+// Run draws the board in the terminal until the events channel is closed,
+// and sends the engine actions for the keys typed into it back to the engine.
+func Run(p gol.Params, events <-chan gol.Event, keyPresses chan<- rune, bindings gol.Bindings) {
+	restore, err := rawMode()
+	if err != nil {
+		fmt.Println("Could not put the terminal in raw mode, press enter after each key:", err)
+	}
+	go readKeys(keyPresses, bindings)
+
+	// Ctrl-C would otherwise leave the terminal in raw mode with no cursor
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	finished := make(chan struct{})
+	defer func() {
+		signal.Stop(interrupt)
+		close(finished)
+	}()
+	go func() {
+		select {
+		case <-interrupt:
+		case <-finished:
+			return
+		}
+		os.Stdout.WriteString(showCursor + "\n")
+		if restore != nil {
+			restore()
+		}
+		os.Exit(130)
+	}()
+
+	t := &terminal{
+		width:  p.ImageWidth,
+		height: p.ImageHeight,
+		alive:  make([]bool, p.ImageWidth*p.ImageHeight),
+		out:    bufio.NewWriterSize(os.Stdout, 1<<16),
+		state:  gol.Executing,
+	}
+	t.out.WriteString(hideCursor + clearScreen)
+
+termLoop:
+	for event := range events {
+		switch e := event.(type) {
+		case gol.CellFlipped:
+			t.flip(e.Cell.X, e.Cell.Y)
+		case gol.CellsFlipped:
+			for _, cell := range e.Cells {
+				t.flip(cell.X, cell.Y)
+			}
+		case gol.TurnComplete:
+			t.turns = e.CompletedTurns
+			if time.Since(t.drawn) >= frameInterval {
+				t.draw()
+			}
+		case gol.StateChange:
+			t.turns = e.CompletedTurns
+			t.state = e.NewState
+			t.draw()
+		case gol.FinalTurnComplete:
+			t.turns = e.CompletedTurns
+			t.draw()
+			break termLoop
+		default:
+			if len(event.String()) > 0 {
+				t.status = event.String()
+			}
+		}
+	}
+
+	t.out.WriteString(showCursor + "\n")
+	t.out.Flush()
+	if restore != nil {
+		restore()
+	}
+}
+
+func (t *terminal) flip(x, y int) {
+	t.alive[y*t.width+x] = !t.alive[y*t.width+x]
+	if t.alive[y*t.width+x] {
+		t.population++
+	} else {
+		t.population--
+	}
+}
+
+// size finds how much of the terminal there is to draw in, checking again every so often in case it was resized.
+func (t *terminal) size() {
+	if time.Since(t.sized) < time.Second {
+		return
+	}
+	t.sized = time.Now()
+	t.rows, t.cols = 24, 80
+	cmd := exec.Command("stty", "size")
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	if err == nil {
+		fmt.Sscan(string(out), &t.rows, &t.cols)
+	}
+	if t.rows < minRows {
+		t.rows = minRows
+	}
+	if t.cols < minCols {
+		t.cols = minCols
+	}
+}
+
+// draw writes the whole board to the terminal, shrunk to fit if it has to be.
+// A shrunk block of cells is shown as alive if any cell in it is alive.
+func (t *terminal) draw() {
+	t.drawn = time.Now()
+	t.size()
+	// two lines are kept for the status
+	scale := 1
+	for (t.width+scale-1)/scale > t.cols || (t.height+2*scale-1)/(2*scale) > t.rows-2 {
+		scale++
+	}
+	cols := (t.width + scale - 1) / scale
+	rows := (t.height + scale - 1) / scale
+
+	t.out.WriteString(home)
+	for row := 0; row < rows; row += 2 {
+		for col := 0; col < cols; col++ {
+			block := 0
+			if t.anyAlive(col, row, scale) {
+				block |= 1
+			}
+			if row+1 < rows && t.anyAlive(col, row+1, scale) {
+				block |= 2
+			}
+			t.out.WriteString(blocks[block])
+		}
+		t.out.WriteString(clearLine + "\n")
+	}
+
+	fmt.Fprintf(t.out, "Turn %d | Alive %d | %v | %v", t.turns, t.population, t.state, gol.Rule)
+	if scale > 1 {
+		fmt.Fprintf(t.out, " | 1:%d", scale)
+	}
+	t.out.WriteString(clearLine + "\n")
+	t.out.WriteString(t.status + clearLine)
+	t.out.Flush()
+}
+
+// anyAlive reports whether any cell in a scale x scale block is alive.
+func (t *terminal) anyAlive(col, row, scale int) bool {
+	for y := row * scale; y < (row+1)*scale && y < t.height; y++ {
+		for x := col * scale; x < (col+1)*scale && x < t.width; x++ {
+			if t.alive[y*t.width+x] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rawMode stops the terminal from waiting for enter and echoing keys, giving a function to put it back.
+// Ctrl-C still works as usual.
+func rawMode() (func(), error) {
+	stty := func(args ...string) (string, error) {
+		cmd := exec.Command("stty", args...)
+		cmd.Stdin = os.Stdin
+		out, err := cmd.Output()
+		return strings.TrimSpace(string(out)), err
+	}
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	_, err = stty("-icanon", "-echo", "min", "1")
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		stty(saved)
+	}, nil
+}
+
+// readKeys sends on the engine actions bound to keys as they are typed.
+// Only character keys can be bound here, as the terminal gives no names for the others.
+func readKeys(keyPresses chan<- rune, bindings gol.Bindings) {
+	in := bufio.NewReader(os.Stdin)
+	for {
+		char, _, err := in.ReadRune()
+		if err != nil {
+			return
+		}
+		if key := bindings.Action(string(char)).Key(); key != 0 {
+			keyPresses <- key
+		}
+	}
+}
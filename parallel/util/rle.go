@@ -0,0 +1,217 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// rleLineLength is the longest line written in an RLE pattern, as the format asks.
+const rleLineLength = 70
+
+// Pattern is a rectangle of cells, with the alive cells given relative to its top left corner.
+type Pattern struct {
+	Width, Height int
+	Alive         []Cell
+}
+
+// RLE writes the pattern in the run length encoded format used by most Game of Life software.
+func (p Pattern) RLE() string {
+	rows := make([][]bool, p.Height)
+	for y := range rows {
+		rows[y] = make([]bool, p.Width)
+	}
+	for _, cell := range p.Alive {
+		rows[cell.Y][cell.X] = true
+	}
+
+	var body strings.Builder
+	run := func(count int, tag byte) {
+		if count > 1 {
+			body.WriteString(strconv.Itoa(count))
+		}
+		body.WriteByte(tag)
+	}
+	// end of line runs are only written once there is something alive on a later row
+	newLines := 0
+	for y, row := range rows {
+		if y > 0 {
+			newLines++
+		}
+		last := len(row) - 1
+		for last >= 0 && !row[last] {
+			last--
+		}
+		if last < 0 {
+			continue
+		}
+		if newLines > 0 {
+			run(newLines, '$')
+			newLines = 0
+		}
+		for x := 0; x <= last; {
+			count := 1
+			for x+count <= last && row[x+count] == row[x] {
+				count++
+			}
+			if row[x] {
+				run(count, 'o')
+			} else {
+				run(count, 'b')
+			}
+			x += count
+		}
+	}
+	body.WriteByte('!')
+
+	var rle strings.Builder
+	fmt.Fprintf(&rle, "x = %d, y = %d, rule = B3/S23\n", p.Width, p.Height)
+	// lines are only broken between runs, never inside a run's count
+	line := 0
+	text := body.String()
+	for start := 0; start < len(text); {
+		end := start
+		for end < len(text) && text[end] >= '0' && text[end] <= '9' {
+			end++
+		}
+		end++
+		if line+end-start > rleLineLength {
+			rle.WriteByte('\n')
+			line = 0
+		}
+		rle.WriteString(text[start:end])
+		line += end - start
+		start = end
+	}
+	rle.WriteByte('\n')
+	return rle.String()
+}
+
+// ParseRLE reads a pattern in the run length encoded format.
+// Lines starting with # are comments, and any cell state other than dead is taken as alive.
+// A pattern bigger than maxWidth x maxHeight is refused as soon as that is clear,
+// so that however big a pattern claims to be, reading it can't take more than that many cells.
+func ParseRLE(text string, maxWidth, maxHeight int) (Pattern, error) {
+	var p Pattern
+	header := false
+	x, y, count := 0, 0, 0
+	width, height := 0, 0
+
+parse:
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !header && strings.HasPrefix(line, "x") {
+			header = true
+			for _, field := range strings.Split(line, ",") {
+				parts := strings.SplitN(field, "=", 2)
+				if len(parts) != 2 {
+					return p, fmt.Errorf("bad RLE header %q", line)
+				}
+				name := strings.TrimSpace(parts[0])
+				value, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+				switch {
+				case (name == "x" || name == "y") && (err != nil || value < 0):
+					return p, fmt.Errorf("bad RLE header %q", line)
+				case name == "x":
+					p.Width = value
+				case name == "y":
+					p.Height = value
+				}
+			}
+			if p.Width > maxWidth || p.Height > maxHeight {
+				return p, tooBig(p.Width, p.Height, maxWidth, maxHeight)
+			}
+			continue
+		}
+
+		for _, char := range line {
+			switch {
+			case char >= '0' && char <= '9':
+				// a run longer than the pattern can be is refused anyway, so it is kept from overflowing
+				count = clamp(10*count+int(char-'0'), maxWidth+maxHeight+1)
+				continue
+			case char == ' ' || char == '\t' || char == '\r':
+				continue
+			}
+			if count == 0 {
+				count = 1
+			}
+			switch {
+			case char == '!':
+				break parse
+			case char == '$':
+				y += count
+				x = 0
+			case char == 'b' || char == '.':
+				x += count
+			case char >= 'a' && char <= 'z' || char >= 'A' && char <= 'Z':
+				if y >= maxHeight || x+count > maxWidth {
+					return p, tooBig(x+count, y+1, maxWidth, maxHeight)
+				}
+				for i := 0; i < count; i++ {
+					p.Alive = append(p.Alive, Cell{X: x + i, Y: y})
+				}
+				if y+1 > height {
+					height = y + 1
+				}
+				x += count
+				if x > width {
+					width = x
+				}
+			default:
+				return p, fmt.Errorf("unexpected %q in RLE", char)
+			}
+			count = 0
+		}
+	}
+
+	if !header && len(p.Alive) == 0 {
+		return p, errors.New("no RLE pattern found")
+	}
+	// the header can be left out, or be smaller than the cells given
+	if width > p.Width {
+		p.Width = width
+	}
+	if height > p.Height {
+		p.Height = height
+	}
+	return p, nil
+}
+
+// tooBig is the error for a pattern that won't fit on the board.
+func tooBig(width, height, maxWidth, maxHeight int) error {
+	return fmt.Errorf("RLE pattern is at least %dx%d, bigger than the %dx%d board", width, height, maxWidth, maxHeight)
+}
+
+// clamp keeps a size between 0 and most.
+func clamp(size, most int) int {
+	if size < 0 {
+		return 0
+	}
+	if size > most {
+		return most
+	}
+	return size
+}
+
+// Rotate gives the pattern turned a quarter turn clockwise.
+func (p Pattern) Rotate() Pattern {
+	rotated := Pattern{Width: p.Height, Height: p.Width, Alive: make([]Cell, len(p.Alive))}
+	for i, cell := range p.Alive {
+		rotated.Alive[i] = Cell{X: p.Height - 1 - cell.Y, Y: cell.X}
+	}
+	return rotated
+}
+
+// Mirror gives the pattern flipped left to right.
+func (p Pattern) Mirror() Pattern {
+	mirrored := Pattern{Width: p.Width, Height: p.Height, Alive: make([]Cell, len(p.Alive))}
+	for i, cell := range p.Alive {
+		mirrored.Alive[i] = Cell{X: p.Width - 1 - cell.X, Y: cell.Y}
+	}
+	return mirrored
+}
@@ -0,0 +1,114 @@
+package util
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func sorted(cells []Cell) []Cell {
+	cells = append([]Cell(nil), cells...)
+	sort.Slice(cells, func(i, j int) bool {
+		if cells[i].Y != cells[j].Y {
+			return cells[i].Y < cells[j].Y
+		}
+		return cells[i].X < cells[j].X
+	})
+	return cells
+}
+
+// TestRLERoundTrip checks that a written pattern reads back the same.
+func TestRLERoundTrip(t *testing.T) {
+	wide := Pattern{Width: 200, Height: 1}
+	for x := 0; x < 200; x += 2 {
+		wide.Alive = append(wide.Alive, Cell{X: x, Y: 0})
+	}
+	tests := []struct {
+		name    string
+		pattern Pattern
+		rle     string
+	}{
+		{"empty", Pattern{Width: 3, Height: 2}, "x = 3, y = 2, rule = B3/S23\n!\n"},
+		{"glider", Pattern{Width: 3, Height: 3, Alive: []Cell{{1, 0}, {2, 1}, {0, 2}, {1, 2}, {2, 2}}},
+			"x = 3, y = 3, rule = B3/S23\nbo$2bo$3o!\n"},
+		{"blank rows", Pattern{Width: 4, Height: 5, Alive: []Cell{{0, 0}, {3, 4}}},
+			"x = 4, y = 5, rule = B3/S23\no4$3bo!\n"},
+		{"long line", wide, ""},
+	}
+	for _, test := range tests {
+		rle := test.pattern.RLE()
+		if test.rle != "" && rle != test.rle {
+			t.Errorf("%s: wrote %q, want %q", test.name, rle, test.rle)
+		}
+		for _, line := range strings.Split(rle, "\n") {
+			if len(line) > rleLineLength {
+				t.Errorf("%s: line of %d characters written", test.name, len(line))
+			}
+		}
+		parsed, err := ParseRLE(rle, 256, 256)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if parsed.Width != test.pattern.Width || parsed.Height != test.pattern.Height ||
+			!reflect.DeepEqual(sorted(parsed.Alive), sorted(test.pattern.Alive)) {
+			t.Errorf("%s: read back %+v, want %+v", test.name, parsed, test.pattern)
+		}
+	}
+}
+
+// TestParseRLE checks reading patterns written by hand, and refusing bad or oversized ones.
+func TestParseRLE(t *testing.T) {
+	tests := []struct {
+		name  string
+		rle   string
+		want  Pattern
+		error string
+	}{
+		{name: "runs of cells", rle: "x = 6, y = 1\n2b3ob!",
+			want: Pattern{Width: 6, Height: 1, Alive: []Cell{{2, 0}, {3, 0}, {4, 0}}}},
+		{name: "runs of line ends", rle: "x = 1, y = 4\no3$o!",
+			want: Pattern{Width: 1, Height: 4, Alive: []Cell{{0, 0}, {0, 3}}}},
+		{name: "stops at !", rle: "x = 2, y = 2\no!\nbo$o!",
+			want: Pattern{Width: 2, Height: 2, Alive: []Cell{{0, 0}}}},
+		{name: "comments", rle: "#N Blinker\n#C a comment with o and $ in it\nx = 3, y = 1\n#C between lines\n3o!",
+			want: Pattern{Width: 3, Height: 1, Alive: []Cell{{0, 0}, {1, 0}, {2, 0}}}},
+		{name: "no header", rle: "2o$2o!",
+			want: Pattern{Width: 2, Height: 2, Alive: []Cell{{0, 0}, {1, 0}, {0, 1}, {1, 1}}}},
+		{name: "header smaller than cells", rle: "x = 1, y = 1\n3o!",
+			want: Pattern{Width: 3, Height: 1, Alive: []Cell{{0, 0}, {1, 0}, {2, 0}}}},
+		{name: "other states", rle: "x = 2, y = 1\nAB!",
+			want: Pattern{Width: 2, Height: 1, Alive: []Cell{{0, 0}, {1, 0}}}},
+		{name: "split runs", rle: "x = 12, y = 1\n1\n2o!",
+			want: Pattern{Width: 12, Height: 1, Alive: sorted([]Cell{{0, 0}, {1, 0}, {2, 0}, {3, 0}, {4, 0}, {5, 0}, {6, 0}, {7, 0}, {8, 0}, {9, 0}, {10, 0}, {11, 0}})}},
+
+		{name: "width not a number", rle: "x = three, y = 1\n3o!", error: "bad RLE header"},
+		{name: "negative height", rle: "x = 3, y = -1\n3o!", error: "bad RLE header"},
+		{name: "field without value", rle: "x = 3, y\n3o!", error: "bad RLE header"},
+		{name: "unexpected character", rle: "x = 3, y = 1\n2o?!", error: "unexpected"},
+		{name: "nothing", rle: "#C only a comment\n", error: "no RLE pattern"},
+		{name: "header too wide", rle: "x = 17, y = 1\no!", error: "bigger than the 16x16 board"},
+		{name: "header too high", rle: "x = 1, y = 17\no!", error: "bigger than the 16x16 board"},
+		{name: "cells too wide", rle: "16bo!", error: "bigger than the 16x16 board"},
+		{name: "cells too high", rle: "16$o!", error: "bigger than the 16x16 board"},
+		{name: "huge run", rle: "99999999999999999999999o!", error: "bigger than the 16x16 board"},
+	}
+	for _, test := range tests {
+		got, err := ParseRLE(test.rle, 16, 16)
+		if test.error != "" {
+			if err == nil || !strings.Contains(err.Error(), test.error) {
+				t.Errorf("%s: got error %v, want one containing %q", test.name, err, test.error)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		got.Alive = sorted(got.Alive)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package web
+
+// page is the viewer. It keeps its own copy of the board, applying each diff as it arrives.
+const page = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Game of Life</title>
+<style>
+  body { background: #222; color: #ddd; font-family: sans-serif; margin: 1em; }
+  canvas { background: #000; image-rendering: pixelated; display: block; margin-bottom: 0.5em; }
+  button { margin-right: 0.3em; }
+  #stats { margin-bottom: 0.5em; }
+</style>
+</head>
+<body>
+<div id="stats">Connecting...</div>
+<canvas id="board"></canvas>
+<div>
+  <button data-key="p">Pause (p)</button>
+  <button data-key="n">Step (n)</button>
+  <button data-key="-">Slower (-)</button>
+  <button data-key="+">Faster (+)</button>
+  <button data-key="s">Save (s)</button>
+  <button data-key="q">Quit (q)</button>
+  <button data-key="k">Kill (k)</button>
+</div>
+<script>
+const canvas = document.getElementById("board");
+const ctx = canvas.getContext("2d");
+const statsText = document.getElementById("stats");
+let width = 0, height = 0, scale = 1, cells = null;
+
+function drawCell(x, y) {
+  ctx.fillStyle = cells[y * width + x] ? "#fff" : "#000";
+  ctx.fillRect(x * scale, y * scale, scale, scale);
+}
+
+function fit() {
+  const space = Math.min(window.innerWidth - 40, window.innerHeight - 120);
+  scale = Math.max(1, Math.floor(space / Math.max(width, height)));
+  canvas.width = width * scale;
+  canvas.height = height * scale;
+}
+
+function drawAll() {
+  ctx.fillStyle = "#000";
+  ctx.fillRect(0, 0, canvas.width, canvas.height);
+  ctx.fillStyle = "#fff";
+  for (let i = 0; i < cells.length; i++) {
+    if (cells[i]) {
+      ctx.fillRect((i % width) * scale, Math.floor(i / width) * scale, scale, scale);
+    }
+  }
+}
+
+const source = new EventSource("/events");
+source.addEventListener("board", e => {
+  const b = JSON.parse(e.data);
+  width = b.width;
+  height = b.height;
+  cells = new Uint8Array(width * height);
+  for (let i = 0; i < b.alive.length; i += 2) {
+    cells[b.alive[i + 1] * width + b.alive[i]] = 1;
+  }
+  fit();
+  drawAll();
+});
+source.addEventListener("diff", e => {
+  const d = JSON.parse(e.data);
+  for (let i = 0; i < d.flipped.length; i += 2) {
+    const x = d.flipped[i], y = d.flipped[i + 1];
+    cells[y * width + x] ^= 1;
+    drawCell(x, y);
+  }
+});
+source.addEventListener("stats", e => {
+  const s = JSON.parse(e.data);
+  statsText.textContent = "Turn " + s.turn + " | Alive " + s.alive + " | " +
+    s.turnsPerSecond.toFixed(1) + " turns/s | " + s.state + " | " + s.rule;
+  if (s.state === "Finished") {
+    source.close();
+  }
+});
+
+// the token shows the key came from this page, not another site the browser has open
+const token = "{{token}}";
+function sendKey(key) {
+  fetch("/key", {method: "POST", body: new URLSearchParams({key: key, token: token})});
+}
+document.addEventListener("keydown", e => {
+  const key = e.key === "=" ? "+" : e.key;
+  if ("psqkn+-".includes(key) && key.length === 1) {
+    sendKey(key);
+  }
+});
+for (const button of document.querySelectorAll("button")) {
+  button.addEventListener("click", () => sendKey(button.dataset.key));
+}
+window.addEventListener("resize", () => { if (cells) { fit(); drawAll(); } });
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,50 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestKey checks that key presses need the page's token, and are turned away rather than waited on
+// when the run isn't taking them.
+func TestKey(t *testing.T) {
+	keyPresses := make(chan rune, 1)
+	s := &server{mutex: &sync.Mutex{}, keyPresses: keyPresses, token: "secret"}
+
+	page := httptest.NewRecorder()
+	s.page(page, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !strings.Contains(page.Body.String(), `"secret"`) {
+		t.Error("the page doesn't hold the token")
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"no token", "", http.StatusForbidden},
+		{"wrong token", "guess", http.StatusForbidden},
+		{"token", "secret", http.StatusNoContent},
+		{"busy", "secret", http.StatusServiceUnavailable},
+	}
+	for _, test := range tests {
+		form := url.Values{"key": {"p"}}
+		if test.token != "" {
+			form.Set("token", test.token)
+		}
+		request := httptest.NewRequest(http.MethodPost, "/key", strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		response := httptest.NewRecorder()
+		s.key(response, request)
+		if response.Code != test.want {
+			t.Errorf("%s: got status %d, want %d", test.name, response.Code, test.want)
+		}
+	}
+	if len(keyPresses) != 1 {
+		t.Errorf("%d key presses sent, want 1", len(keyPresses))
+	}
+}